@@ -2,13 +2,16 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/mobile/event/lifecycle"
 	"golang.org/x/mobile/event/paint"
+	"sigint.ca/graphics/editor"
 	"sigint.ca/graphics/editor/address"
 )
 
@@ -24,11 +27,17 @@ func (p *pane) findInEditor(s string) {
 	}
 }
 
-func (p *pane) executeCmd(cmd string) {
+// executeCmd executes cmd, which was selected with B2 in one of p's
+// widgets. If arg is not empty, it is passed to cmd as a single
+// argument, which may contain spaces.
+func (p *pane) executeCmd(cmd, arg string) {
 	args := strings.Fields(cmd)
 	if len(args) == 0 {
 		return
 	}
+	if arg != "" {
+		args = append(args, arg)
+	}
 
 	switch args[0] {
 	case "Put":
@@ -69,13 +78,22 @@ func (p *pane) executeCmd(cmd string) {
 		} else {
 			return
 		}
+	case "Tab":
+		p.setTab(args[1:])
+	case "Edit":
+		// the script is the rest of the command, followed by arg
+		script := strings.TrimPrefix(strings.TrimSpace(cmd), "Edit")
+		if arg != "" {
+			script += " " + arg
+		}
+		p.edit(script)
 
 	default:
 		switch args[0][0] {
 		case '|':
-			p.pipe(cmd[1:])
+			pipe(p.main.ed, pipeArgs(args))
 		default:
-			p.run(cmd)
+			p.run(args)
 		}
 	}
 
@@ -83,6 +101,31 @@ func (p *pane) executeCmd(cmd string) {
 	p.tag.ed.SetDot(address.Selection{From: end, To: end})
 }
 
+// setTab sets the tab width of the pane's main editor widget from args,
+// which may be a number of columns, or "elastic" to toggle elastic tabstops.
+func (p *pane) setTab(args []string) {
+	if len(args) != 1 {
+		p.errorf("usage: Tab n | Tab elastic")
+		return
+	}
+	if args[0] == "elastic" {
+		p.opts.ElasticTabstops = !p.opts.ElasticTabstops
+	} else {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			p.errorf("Tab: bad tab width %q", args[0])
+			return
+		}
+		p.opts.TabWidth = n
+	}
+	p.main.ed.SetOpts(&p.opts)
+}
+
+// errorf reports an error to the user in a new +Errors pane.
+func (p *pane) errorf(format string, args ...interface{}) {
+	addPane(p.cwd+"+Errors", []byte(fmt.Sprintf(format, args...)+"\n"))
+}
+
 const confirmDuration = 3 * time.Second
 
 func (p *pane) confirmUnsaved() bool {
@@ -95,12 +138,31 @@ func (p *pane) confirmUnsaved() bool {
 	return confirmed
 }
 
-func (p *pane) pipe(cmd string) {
-	ed := p.main.ed
+// executeChord executes cmd, which was selected with B2 in w, with the
+// argument of a B2-B1 chord. As in acme, the argument is the text most
+// recently selected with B1, which may be in another widget. A command
+// beginning with '|' pipes the argument rather than taking it as an
+// argument.
+func (p *pane) executeChord(w *widget, cmd, arg string) {
+	src := w
+	if lastB1 != nil && lastB1 != w {
+		src = lastB1
+		arg = src.ed.GetDotContents()
+	}
+	if !strings.HasPrefix(cmd, "|") {
+		p.executeCmd(cmd, arg)
+		return
+	}
+	pipe(src.ed, pipeArgs(strings.Fields(cmd)))
+	end := p.tag.ed.LastAddress()
+	p.tag.ed.SetDot(address.Selection{From: end, To: end})
+}
 
+// pipe replaces the selection of ed with the output of the command args,
+// which is given the selection as input.
+func pipe(ed *editor.Editor, args []string) {
 	in := bytes.NewBufferString(ed.GetDotContents())
 	out := new(bytes.Buffer)
-	args := strings.Fields(cmd)
 	if len(args) == 0 {
 		return
 	}
@@ -113,14 +175,23 @@ func (p *pane) pipe(cmd string) {
 	ed.Replace(out.String())
 }
 
-func (p *pane) run(cmd string) {
-	args := strings.Fields(cmd)
+// pipeArgs returns the arguments of a command beginning with '|', without
+// the '|', which may be separated from the command by spaces.
+func pipeArgs(args []string) []string {
+	args[0] = args[0][1:]
+	if args[0] == "" {
+		return args[1:]
+	}
+	return args
+}
+
+func (p *pane) run(args []string) {
 	go func() {
 		command := exec.Command(args[0], args[1:]...)
 		command.Dir = p.cwd
 		out, err := command.CombinedOutput()
 		if err != nil || len(out) == 0 {
-			dprintf("failed to run cmd=%q: %v", cmd, err)
+			dprintf("failed to run args=%q: %v", args, err)
 			return
 		}
 		addPane(p.cwd+"+Errors", out)
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigint.ca/graphics/editor/address"
+)
+
+// edit runs script, a command in the language of sam(1), on the pane's
+// main editor widget. Only a subset of the language is supported: an
+// optional address, which is "," for the whole file, "." for dot, or
+// /re/ for the next match of re, followed by one of the commands
+// s/re/text/[g], a/text/, i/text/, c/text/, d, or |cmd. A script with
+// only an address selects it.
+func (p *pane) edit(script string) {
+	ed := p.main.ed
+	script = strings.TrimSpace(script)
+	if script == "" {
+		p.errorf("usage: Edit [address] command")
+		return
+	}
+
+	switch script[0] {
+	case ',':
+		ed.SetDot(address.Selection{To: ed.LastAddress()})
+		script = script[1:]
+	case '.':
+		script = script[1:]
+	case '/':
+		re, rest := splitDelim(script[1:], '/')
+		if !ed.JumpTo("/" + re + "/") {
+			p.errorf("Edit: no match for /%s/", re)
+			return
+		}
+		script = rest
+	}
+	script = strings.TrimSpace(script)
+	if script == "" {
+		return
+	}
+
+	switch cmd := script[0]; cmd {
+	case 'd':
+		ed.Replace("")
+	case 'a', 'i', 'c':
+		if len(script) < 2 {
+			p.errorf("usage: Edit %c/text/", cmd)
+			return
+		}
+		text, _ := splitDelim(script[2:], script[1])
+		text = strings.Replace(text, `\n`, "\n", -1)
+		dot := ed.GetDot()
+		if cmd == 'a' {
+			ed.SetDot(address.Selection{From: dot.To, To: dot.To})
+		} else if cmd == 'i' {
+			ed.SetDot(address.Selection{From: dot.From, To: dot.From})
+		}
+		ed.Replace(text)
+	case 's':
+		if len(script) < 2 {
+			p.errorf("usage: Edit s/re/text/[g]")
+			return
+		}
+		pattern, rest := splitDelim(script[2:], script[1])
+		text, rest := splitDelim(rest, script[1])
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.errorf("Edit: %v", err)
+			return
+		}
+		s, err := substitute(ed.GetDotContents(), re, text, strings.TrimSpace(rest) == "g")
+		if err != nil {
+			p.errorf("Edit: %v", err)
+			return
+		}
+		ed.Replace(s)
+	case '|':
+		pipe(ed, strings.Fields(script[1:]))
+	default:
+		p.errorf("Edit: unknown command %q", cmd)
+	}
+}
+
+// splitDelim returns the text of s preceding the first occurrence of
+// delim which isn't escaped by a backslash, with such escapes removed,
+// and the text following it. If there is no such occurrence, all of s
+// is returned.
+func splitDelim(s string, delim byte) (text, rest string) {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == delim:
+			return string(buf), s[i+1:]
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			i++
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf), ""
+}
+
+// substitute replaces the first match of re in s, or every match if
+// global is set, with text, in which & stands for the match and \n
+// for its nth subexpression, as in sam(1).
+func substitute(s string, re *regexp.Regexp, text string, global bool) (string, error) {
+	var template []byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '&':
+			template = append(template, "${0}"...)
+		case c == '$':
+			template = append(template, "$$"...)
+		case c == '\\' && i+1 < len(text):
+			i++
+			switch c := text[i]; {
+			case c >= '0' && c <= '9':
+				template = append(template, "${"+string(c)+"}"...)
+			case c == 'n':
+				template = append(template, '\n')
+			case c == '$':
+				template = append(template, "$$"...)
+			default:
+				template = append(template, c)
+			}
+		default:
+			template = append(template, c)
+		}
+	}
+
+	if global {
+		return re.ReplaceAllString(s, string(template)), nil
+	}
+	m := re.FindStringSubmatchIndex(s)
+	if m == nil {
+		return "", fmt.Errorf("no match for %s", re)
+	}
+	return s[:m[0]] + string(re.ExpandString(nil, string(template), s, m)) + s[m[1]:], nil
+}
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
@@ -16,6 +17,10 @@ const fontSize = 13
 var (
 	ttfFont  *truetype.Font
 	fontFace font.Face
+
+	// fallback fonts, for runes which ttfFont has no glyphs for
+	ttfFallbacks  []*truetype.Font
+	fallbackFaces []font.Face
 )
 
 func loadFont() {
@@ -33,6 +38,31 @@ func loadFont() {
 	if err != nil {
 		log.Fatalf("error parsing ttf data: %v", err)
 	}
+
+	for _, path := range filepath.SplitList(os.Getenv("FONTFALLBACK")) {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Printf("error reading FONTFALLBACK font %s: %v", path, err)
+			continue
+		}
+		f, err := truetype.Parse(buf)
+		if err != nil {
+			log.Printf("error parsing FONTFALLBACK font %s: %v", path, err)
+			continue
+		}
+		ttfFallbacks = append(ttfFallbacks, f)
+	}
+}
+
+// ttfFace is a truetype face which reports whether it has a glyph
+// for a rune, so that the editor can find a fallback face.
+type ttfFace struct {
+	font.Face
+	f *truetype.Font
+}
+
+func (f ttfFace) HasGlyph(r rune) bool {
+	return f.f.Index(r) != 0
 }
 
 func updateFont(e size.Event) {
@@ -45,5 +75,10 @@ func updateFont(e size.Event) {
 		DPI:     float64(dpi),
 		Hinting: font.HintingNone,
 	}
-	fontFace = truetype.NewFace(ttfFont, &opts)
+	fontFace = ttfFace{truetype.NewFace(ttfFont, &opts), ttfFont}
+
+	fallbackFaces = nil
+	for _, f := range ttfFallbacks {
+		fallbackFaces = append(fallbackFaces, ttfFace{truetype.NewFace(f, &opts), f})
+	}
 }
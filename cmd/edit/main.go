@@ -7,6 +7,9 @@ import (
 	"image/color"
 	"log"
 	"os"
+	"time"
+
+	"sigint.ca/graphics/editor"
 
 	"golang.org/x/exp/shiny/driver"
 	"golang.org/x/exp/shiny/screen"
@@ -28,6 +31,10 @@ var (
 
 	panes   []*pane
 	widgets []*widget
+
+	// lastB1 is the widget in which text was most recently selected
+	// with B1, which provides the argument of a B2-B1 chord
+	lastB1 *widget
 )
 
 var (
@@ -75,6 +82,8 @@ func main() {
 		}
 
 		selWidget := panes[0].main
+		b2held := false
+		ticking := false // a tickEvent is pending
 
 		var lastSize image.Point
 		for {
@@ -108,11 +117,29 @@ func main() {
 						selWidget = w
 					}
 				}
+				if e.Button == mouse.ButtonMiddle {
+					b2held = e.Direction == mouse.DirPress
+				} else if e.Button == mouse.ButtonLeft && e.Direction == mouse.DirPress && !b2held {
+					lastB1 = selWidget
+				}
 				e.Pos = e.Pos.Sub(selWidget.r.Min)
 
 				selWidget.ed.SendMouseEvent(e)
+				if !ticking && selWidget.ed.Animating() {
+					ticking = true
+					sendTick()
+				}
 				win.Send(paint.Event{})
 
+			case tickEvent:
+				ticking = false
+				if selWidget.ed.Animating() {
+					selWidget.ed.Tick()
+					ticking = true
+					sendTick()
+					win.Send(paint.Event{})
+				}
+
 			case paint.Event:
 				if lastSize != winSize {
 					dprintf("resizing panes")
@@ -157,11 +184,15 @@ func main() {
 					updateFont(e)
 
 					for _, p := range panes {
+						p.tag.ed.SetPixelsPerPt(pixelsPerPt)
 						p.tag.ed.SetFont(fontFace)
+						p.tag.ed.SetFallbackFonts(fallbackFaces...)
 						m := fontFace.Metrics()
 						tagHeight = (m.Ascent + m.Descent).Round()
 
+						p.main.ed.SetPixelsPerPt(pixelsPerPt)
 						p.main.ed.SetFont(fontFace)
+						p.main.ed.SetFallbackFonts(fallbackFaces...)
 					}
 				}
 				winSize = e.Size()
@@ -177,3 +208,14 @@ func main() {
 		}
 	})
 }
+
+// A tickEvent is sent to the window while an editor is animating, e.g.
+// autoscrolling during a sweep.
+type tickEvent struct{}
+
+// sendTick sends a tickEvent to the window after editor.TickInterval.
+func sendTick() {
+	time.AfterFunc(editor.TickInterval, func() {
+		win.Send(tickEvent{})
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"sigint.ca/graphics/editor"
 	"sigint.ca/graphics/editor/address"
+	"sigint.ca/graphics/editor/highlight"
 )
 
 var npanes int
@@ -18,6 +19,10 @@ type pane struct {
 	tag, main *widget
 	pos       int
 
+	// opts is the main editor widget's OptionSet, which is
+	// private to the pane so that it can be changed by commands.
+	opts editor.OptionSet
+
 	savedPath   string
 	currentPath string
 	dir         bool
@@ -46,7 +51,8 @@ func newPane(name string, data []byte) (*pane, error) {
 
 	// set up the main editor widget
 	sz, pt := p.mainDimensions()
-	p.main = p.newWidget(sz, pt, editor.AcmeYellowTheme, fontFace)
+	p.opts = *editor.AcmeYellowTheme
+	p.main = p.newWidget(sz, pt, &p.opts, fontFace)
 
 	// load text into main editor widget
 	if data != nil {
@@ -58,6 +64,15 @@ func newPane(name string, data []byte) (*pane, error) {
 		}
 	}
 
+	switch filepath.Ext(name) {
+	case ".go":
+		p.main.ed.SetHighlighter(highlight.NewGo())
+	case ".md", ".txt":
+		// wrap prose rather than scrolling it horizontally
+		p.opts.Wrap = true
+		p.main.ed.SetOpts(&p.opts)
+	}
+
 	// set up the tag widget
 	sz, pt = p.tagDimensions()
 	p.tag = p.newWidget(sz, pt, editor.AcmeBlueTheme, fontFace)
@@ -69,8 +84,10 @@ func newPane(name string, data []byte) (*pane, error) {
 	p.tag.ed.SetDot(address.Selection{From: end, To: end})
 
 	// set up B2 and B3 actions
-	p.tag.ed.B2Action = p.executeCmd
-	p.main.ed.B2Action = p.executeCmd
+	p.tag.ed.B2Action = func(cmd string) { p.executeCmd(cmd, "") }
+	p.main.ed.B2Action = func(cmd string) { p.executeCmd(cmd, "") }
+	p.tag.ed.B2ArgAction = func(cmd, arg string) { p.executeChord(p.tag, cmd, arg) }
+	p.main.ed.B2ArgAction = func(cmd, arg string) { p.executeChord(p.main, cmd, arg) }
 	p.tag.ed.B3Action = p.findInEditor
 	p.main.ed.B3Action = p.findInEditor
 
@@ -129,6 +146,10 @@ func deletePane(i int) {
 			widgets = append(widgets[:i], widgets[i+1:]...)
 		}
 	}
+	if lastB1 == p.tag || lastB1 == p.main {
+		lastB1 = nil
+	}
+
 	// release widgets
 	p.tag.release()
 	p.main.release()
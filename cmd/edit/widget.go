@@ -28,9 +28,12 @@ func (p *pane) newWidget(size, loc image.Point, opts *editor.OptionSet, face fon
 	if err != nil {
 		log.Fatalf("error creating texture: %v", err)
 	}
+	ed := editor.NewEditor(face, opts)
+	ed.SetPixelsPerPt(pixelsPerPt)
+	ed.SetFallbackFonts(fallbackFaces...)
 	return &widget{
 		pane:  p,
-		ed:    editor.NewEditor(face, opts),
+		ed:    ed,
 		r:     image.Rectangle{loc, loc.Add(size)},
 		buf:   buf,
 		tx:    tx,
@@ -68,8 +71,10 @@ func (w *widget) resize(size, pos image.Point) {
 }
 
 func (w *widget) draw() {
-	w.ed.Draw(w.buf.RGBA(), w.buf.Bounds())
-	w.tx.Upload(image.ZP, w.buf, w.buf.Bounds())
+	// only upload the parts of the buffer which have changed
+	for _, r := range w.ed.Draw(w.buf.RGBA(), w.buf.Bounds()) {
+		w.tx.Upload(r.Min, w.buf, r)
+	}
 	w.dirty = false
 }
 
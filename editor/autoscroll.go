@@ -0,0 +1,75 @@
+package editor
+
+import (
+	"image"
+	"time"
+
+	"golang.org/x/mobile/event/mouse"
+)
+
+// While text is swept or dragged with B1 and the pointer is held beyond
+// an edge of the Editor, the text keeps scrolling, as in acme. Mouse
+// events only arrive when the pointer moves, so the client drives the
+// scrolling by calling Tick every TickInterval while Animating reports
+// true.
+
+// TickInterval is the interval at which Tick should be called while the
+// Editor is animating.
+const TickInterval = 50 * time.Millisecond
+
+// Animating reports whether the Editor is autoscrolling, and so needs
+// Tick to be called after TickInterval.
+func (ed *Editor) Animating() bool {
+	if ed.m.buttons != b1 || ed.m.chording || ed.m.scrolling {
+		return false
+	}
+	if ed.m.drag {
+		if !ed.m.dragged {
+			return false
+		}
+	} else if isTwitch(ed.m.pt, ed.m.sweepOrigin, ed.px(twitch)) {
+		return false
+	}
+	return ed.edgeScrollDelta(ed.m.pos) != image.Point{}
+}
+
+// Tick advances any animation of the Editor. While the Editor is
+// autoscrolling, each Tick scrolls by a number of lines or columns
+// proportional to how far the pointer is past the edge, and extends
+// the sweep as if the pointer had moved.
+func (ed *Editor) Tick() {
+	if !ed.Animating() {
+		return
+	}
+	ed.handleMouseEvent(mouse.Event{
+		Pos:       ed.m.pos,
+		Button:    mouse.ButtonLeft,
+		Direction: mouse.DirNone,
+	})
+}
+
+// edgeScrollDelta returns the amount to scroll the text when the pointer
+// is at pos, relative to the Editor: one line or column when pos is at
+// an edge, and one more for each line height or column width beyond it.
+func (ed *Editor) edgeScrollDelta(pos image.Point) image.Point {
+	vis := ed.visible()
+	var d image.Point
+	if pos.Y <= 0 && vis.Min.Y > 0 {
+		d.Y = ed.fontHeight * (1 + -pos.Y/ed.fontHeight)
+	} else if pos.Y >= ed.r.Dy() && vis.Max.Y < ed.docHeight() {
+		d.Y = -ed.fontHeight * (1 + (pos.Y-ed.r.Dy())/ed.fontHeight)
+	}
+	if ed.wrapping() {
+		return d
+	}
+	cw := ed.digitwidth.Round()
+	if cw < 1 {
+		cw = 1
+	}
+	if left := ed.textLeft(); pos.X < left && vis.Min.X > 0 {
+		d.X = cw * (1 + (left-pos.X)/cw)
+	} else if pos.X >= ed.r.Dx() && vis.Min.X < ed.maxScrollX() {
+		d.X = -cw * (1 + (pos.X-ed.r.Dx())/cw)
+	}
+	return d
+}
@@ -0,0 +1,64 @@
+package editor
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestAutoscroll(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte(strings.Repeat("line\n", 100)))
+	ed.SetDot(address.Selection{})
+	r := image.Rect(0, 0, 200, 50)
+	ed.Draw(image.NewRGBA(r), r)
+
+	x := ed.textLeft() + 1
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, 1), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	if ed.Animating() {
+		t.Error("animating after a press inside the editor")
+	}
+
+	// above the top edge, there is nothing to scroll to
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, -20), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	if ed.Animating() {
+		t.Error("animating above the start of the text")
+	}
+
+	// just past the bottom edge, each tick scrolls one line
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, r.Dy()+1), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	if !ed.Animating() {
+		t.Fatal("not animating below the bottom edge")
+	}
+	y, row := ed.scrollPt().Y, ed.dot.To.Row
+	ed.Tick()
+	if got, want := ed.scrollPt().Y-y, ed.fontHeight; got != want {
+		t.Errorf("tick scrolled by %d pixels, wanted %d", got, want)
+	}
+	if ed.dot.To.Row <= row {
+		t.Errorf("dot ends on row %d after tick, wanted it extended past row %d", ed.dot.To.Row, row)
+	}
+
+	// further past the edge, it scrolls faster
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, r.Dy()+3*ed.fontHeight), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	y = ed.scrollPt().Y
+	ed.Tick()
+	if got, want := ed.scrollPt().Y-y, 4*ed.fontHeight; got != want {
+		t.Errorf("tick scrolled by %d pixels, wanted %d", got, want)
+	}
+
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, r.Dy()+1), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	if ed.Animating() {
+		t.Error("animating after release")
+	}
+	y = ed.scrollPt().Y
+	ed.Tick()
+	if ed.scrollPt().Y != y {
+		t.Error("tick scrolled after release")
+	}
+}
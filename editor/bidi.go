@@ -0,0 +1,448 @@
+package editor
+
+import (
+	"sort"
+
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// A layout is the horizontal layout of a display line. Runes are drawn
+// in visual order, which differs from their logical order in the buffer
+// if the line contains right-to-left text. Columns are always logical.
+type layout struct {
+	adv []fixed.Int26_6 // offsets of runes in visual order, as returned by measureString
+	vis []int           // the visual index of each logical rune; nil if the line is left to right
+	rtl []bool          // whether each logical rune is right to left; nil if vis is
+
+	rtlPara bool // whether the line is a right-to-left paragraph
+}
+
+// left returns the offset of the left edge of the ith logical rune.
+func (l layout) left(i int) fixed.Int26_6 {
+	if l.vis == nil {
+		return l.adv[i]
+	}
+	return l.adv[l.vis[i]]
+}
+
+// right returns the offset of the right edge of the ith logical rune.
+func (l layout) right(i int) fixed.Int26_6 {
+	if l.vis == nil {
+		return l.adv[i+1]
+	}
+	return l.adv[l.vis[i]+1]
+}
+
+// width returns the width of the line.
+func (l layout) width() fixed.Int26_6 {
+	return l.adv[len(l.adv)-1]
+}
+
+// caret returns the offset of the caret at col, which is at the leading
+// edge of the rune at col, or at the trailing edge of the last rune if
+// col is at the end of the line.
+func (l layout) caret(col int) fixed.Int26_6 {
+	n := len(l.adv) - 1
+	if col > n {
+		col = n
+	}
+	if l.vis == nil {
+		return l.adv[col]
+	}
+	if col < n {
+		if l.rtl[col] {
+			return l.right(col)
+		}
+		return l.left(col)
+	}
+	if l.rtl[n-1] {
+		return l.left(n - 1)
+	}
+	return l.right(n - 1)
+}
+
+// col returns the column of the rune containing x. If x is beyond either
+// end of the line, col returns the column at the start or end of the
+// line, according to the line's paragraph direction.
+func (l layout) col(x fixed.Int26_6) int {
+	n := len(l.adv) - 1
+	// the visual index of the rune containing x
+	v := sort.Search(n, func(i int) bool {
+		return l.adv[i+1] > x
+	})
+	if l.vis == nil {
+		if x < l.adv[0] {
+			return 0
+		}
+		return v
+	}
+	if x < l.adv[0] || v == n {
+		if (v == n) != l.rtlPara {
+			return n
+		}
+		return 0
+	}
+	return l.logical(v)
+}
+
+// logical returns the logical index of the rune at visual index v.
+func (l layout) logical(v int) int {
+	for i, vi := range l.vis {
+		if vi == v {
+			return i
+		}
+	}
+	panic("bad visual index")
+}
+
+// ranges returns the horizontal extents of the logical runes [from, to),
+// which are discontiguous if the runes are reordered.
+func (l layout) ranges(from, to int) [][2]fixed.Int26_6 {
+	if from >= to {
+		return nil
+	}
+	if l.vis == nil {
+		return [][2]fixed.Int26_6{{l.adv[from], l.adv[to]}}
+	}
+	vs := append([]int(nil), l.vis[from:to]...)
+	sort.Ints(vs)
+	var rs [][2]fixed.Int26_6
+	for i, v := range vs {
+		if i > 0 && v == vs[i-1]+1 {
+			rs[len(rs)-1][1] = l.adv[v+1]
+		} else {
+			rs = append(rs, [2]fixed.Int26_6{l.adv[v], l.adv[v+1]})
+		}
+	}
+	return rs
+}
+
+// reordering is the visual order of a line containing right-to-left text.
+type reordering struct {
+	visual  string // the runes of the line in visual order
+	vis     []int
+	rtl     []bool
+	rtlPara bool
+}
+
+// layoutLine returns the layout of seg, which is a display line of row.
+// Runes are measured in the faces of their styles, as they are drawn.
+func (ed *Editor) layoutLine(row int, seg segment) layout {
+	s := seg.text
+	faces := spanFaces(sliceSpans(ed.spans(row), seg.start, seg.end), seg.end-seg.start)
+	ro := ed.reorder(s)
+	if ro == nil {
+		return layout{adv: ed.measureLine(row, s, faces)}
+	}
+	return layout{adv: ed.measureVisual(row, s, ro, faces), vis: ro.vis, rtl: ro.rtl, rtlPara: ro.rtlPara}
+}
+
+// reorder returns the visual order of s according to the Unicode
+// Bidirectional Algorithm, or nil if s contains no right-to-left text.
+// Lines are always drawn from the left edge of the Editor, regardless
+// of their paragraph direction.
+func (ed *Editor) reorder(s string) *reordering {
+	if ro, ok := ed.fc.bidi[s]; ok {
+		return ro
+	}
+	ro := reorder(s)
+	if ed.fc.bidi == nil || len(ed.fc.bidi) >= cacheSize {
+		ed.fc.bidi = make(map[string]*reordering)
+	}
+	ed.fc.bidi[s] = ro
+	return ro
+}
+
+func reorder(s string) *reordering {
+	if !hasRTL(s) {
+		return nil
+	}
+	var p bidi.Paragraph
+	if _, err := p.SetString(s); err != nil {
+		return nil
+	}
+	o, err := p.Order()
+	if err != nil {
+		return nil
+	}
+
+	// The Ordering reports the resolved direction of each run, but not
+	// the embedding levels, which are needed to reorder nested runs. The
+	// level of each rune is found from its direction, its explicit
+	// embedding level, and whether it is a number (rules I1 and I2).
+	rs := []rune(s)
+	rtl := make([]bool, len(rs))
+	covered := 0
+	for i := 0; i < o.NumRuns(); i++ {
+		r := o.Run(i)
+		start, end := r.Pos()
+		if start < 0 || end >= len(rs) || start > end {
+			return nil
+		}
+		for j := start; j <= end; j++ {
+			rtl[j] = r.Direction() == bidi.RightToLeft
+		}
+		covered += end - start + 1
+	}
+	if covered != len(rs) {
+		// the runs didn't cover the line
+		return nil
+	}
+
+	rtlPara := isRTLParagraph(rs)
+	para := 0
+	if rtlPara {
+		para = 1
+	}
+	emb, override := embeddings(rs, para)
+	num := numbers(rs, emb)
+	levels := make([]int, len(rs))
+	maxLevel, minOdd := 0, maxDepth+2
+	for i := range rs {
+		e := emb[i]
+		switch {
+		case rtl[i] != (e%2 == 1):
+			levels[i] = e + 1
+		case !rtl[i] && !override[i] && num[i]:
+			levels[i] = e + 2
+		default:
+			levels[i] = e
+		}
+		if levels[i] > maxLevel {
+			maxLevel = levels[i]
+		}
+		if levels[i]%2 == 1 && levels[i] < minOdd {
+			minOdd = levels[i]
+		}
+	}
+
+	// rule L2: from the highest level to the lowest odd level, reverse
+	// any contiguous sequence of runes at that level or higher
+	order := make([]int, len(rs)) // the logical index of each visual rune
+	for i := range order {
+		order[i] = i
+	}
+	for level := maxLevel; level >= minOdd; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+
+	ro := &reordering{
+		vis:     make([]int, len(rs)),
+		rtl:     make([]bool, len(rs)),
+		rtlPara: rtlPara,
+	}
+	visual := make([]rune, len(rs))
+	for v, i := range order {
+		ro.vis[i] = v
+		ro.rtl[i] = levels[i]%2 == 1
+		visual[v] = rs[i]
+		if ro.rtl[i] {
+			// rule L4
+			visual[v] = mirror(rs[i])
+		}
+	}
+	ro.visual = string(visual)
+	return ro
+}
+
+// maxDepth is the maximum explicit embedding level.
+const maxDepth = 125
+
+// embeddings returns the explicit embedding level of each rune of rs, in
+// a paragraph with level para, and whether its direction is overridden
+// (rules X1 to X8). Isolate initiators and terminators have the level of
+// the text around them.
+func embeddings(rs []rune, para int) (levels []int, override []bool) {
+	type entry struct {
+		level    int
+		override bool
+		isolate  bool
+	}
+	stack := []entry{{level: para}}
+	levels = make([]int, len(rs))
+	override = make([]bool, len(rs))
+	for i, r := range rs {
+		top := stack[len(stack)-1]
+		levels[i], override[i] = top.level, top.override
+		p, _ := bidi.LookupRune(r)
+		switch c := p.Class(); c {
+		case bidi.LRE, bidi.LRO, bidi.LRI, bidi.RLE, bidi.RLO, bidi.RLI, bidi.FSI:
+			rtl := c == bidi.RLE || c == bidi.RLO || c == bidi.RLI ||
+				c == bidi.FSI && isRTLParagraph(rs[i+1:])
+			next := (top.level + 2) &^ 1
+			if rtl {
+				next = (top.level + 1) | 1
+			}
+			if next > maxDepth {
+				next = top.level
+			}
+			stack = append(stack, entry{
+				level:    next,
+				override: c == bidi.LRO || c == bidi.RLO,
+				isolate:  c == bidi.LRI || c == bidi.RLI || c == bidi.FSI,
+			})
+		case bidi.PDF:
+			if len(stack) > 1 && !top.isolate {
+				stack = stack[:len(stack)-1]
+			}
+		case bidi.PDI:
+			for j := len(stack) - 1; j > 0; j-- {
+				if stack[j].isolate {
+					stack = stack[:j]
+					break
+				}
+			}
+			top = stack[len(stack)-1]
+			levels[i], override[i] = top.level, top.override
+		}
+	}
+	return levels, override
+}
+
+// numbers reports whether each rune of rs, with explicit embedding levels
+// emb, resolves to a European or Arabic number (rules W1 to W7), which
+// has a higher level than left-to-right text around it.
+func numbers(rs []rune, emb []int) []bool {
+	cls := make([]bidi.Class, len(rs))
+	for i, r := range rs {
+		p, _ := bidi.LookupRune(r)
+		cls[i] = p.Class()
+	}
+	num := make([]bool, len(rs))
+	var last bidi.Class // the last strong type
+	for i, c := range cls {
+		if i == 0 || emb[i] != emb[i-1] {
+			last = bidi.L
+			if emb[i]%2 == 1 {
+				last = bidi.R
+			}
+		}
+		switch c {
+		case bidi.L, bidi.R, bidi.AL:
+			last = c
+		case bidi.AN:
+			num[i] = true
+		case bidi.EN:
+			// rule W7 makes a number following left-to-right text
+			// left to right
+			num[i] = last != bidi.L
+		case bidi.NSM:
+			num[i] = i > 0 && num[i-1]
+		}
+	}
+	for i := 1; i < len(cls)-1; i++ {
+		// rule W4: a single separator between numbers
+		if !num[i-1] || !num[i+1] || cls[i-1] != cls[i+1] {
+			continue
+		}
+		if cls[i] == bidi.CS || cls[i] == bidi.ES && cls[i-1] == bidi.EN {
+			num[i] = true
+		}
+	}
+	for i, c := range cls {
+		// rule W5: terminators adjacent to European numbers
+		if c != bidi.EN || !num[i] {
+			continue
+		}
+		for j := i - 1; j >= 0 && cls[j] == bidi.ET; j-- {
+			num[j] = true
+		}
+		for j := i + 1; j < len(cls) && cls[j] == bidi.ET; j++ {
+			num[j] = true
+		}
+	}
+	return num
+}
+
+// isRTLParagraph reports whether rs is a right-to-left paragraph, which
+// is determined by its first strong character, ignoring any text between
+// an isolate initiator and its matching terminator (rules P2 and P3).
+// An unmatched terminator ends the paragraph, so that rs may also be the
+// text following a first strong isolate.
+func isRTLParagraph(rs []rune) bool {
+	isolates := 0
+	for _, r := range rs {
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.LRI, bidi.RLI, bidi.FSI:
+			isolates++
+		case bidi.PDI:
+			if isolates == 0 {
+				return false
+			}
+			isolates--
+		case bidi.L:
+			if isolates == 0 {
+				return false
+			}
+		case bidi.R, bidi.AL:
+			if isolates == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mirrored holds the mirror images of characters which aren't brackets.
+var mirrored = map[rune]rune{
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+	'‹': '›', '›': '‹',
+	'≤': '≥', '≥': '≤',
+}
+
+// mirror returns the mirror image of r, which is drawn in its place in
+// right-to-left text (rule L4): e.g. ')' for '('.
+func mirror(r rune) rune {
+	if m, ok := mirrored[r]; ok {
+		return m
+	}
+	p, _ := bidi.LookupRune(r)
+	if !p.IsBracket() {
+		return r
+	}
+	// the other bracket of a pair follows an opening bracket, or
+	// precedes a closing bracket, by one or two code points
+	for d := rune(1); d <= 2; d++ {
+		m := r - d
+		if p.IsOpeningBracket() {
+			m = r + d
+		}
+		if q, _ := bidi.LookupRune(m); q.IsBracket() && q.IsOpeningBracket() != p.IsOpeningBracket() {
+			return m
+		}
+	}
+	return r
+}
+
+// hasRTL reports whether s contains any right-to-left text or
+// explicit directional formatting.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if r < 0x0590 {
+			// fast path: nothing before Hebrew is right to left
+			continue
+		}
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.R, bidi.AL, bidi.AN, bidi.RLE, bidi.RLO, bidi.RLI, bidi.FSI:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package editor
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestReorder(t *testing.T) {
+	cases := []struct {
+		s      string
+		visual string
+		vis    []int
+	}{
+		{"abc", "", nil},
+		{"ab אבג", "ab גבא", []int{0, 1, 2, 5, 4, 3}},
+		// a right-to-left paragraph, with embedded left-to-right text
+		{"אב cd", "cd בא", []int{4, 3, 2, 0, 1}},
+		// a right-to-left paragraph beginning with a number
+		{"12 אב", "בא 12", []int{3, 4, 2, 1, 0}},
+		// brackets are mirrored in right-to-left text
+		{"(אב) cd", "cd (בא)", []int{6, 5, 4, 3, 2, 0, 1}},
+		// a number in right-to-left text is at a higher level, and keeps
+		// its order when the text around it is reversed
+		{"ab אב 12 גד", "ab דג 12 בא", []int{0, 1, 2, 10, 9, 8, 6, 7, 5, 4, 3}},
+	}
+	for _, c := range cases {
+		ro := reorder(c.s)
+		if c.vis == nil {
+			if ro != nil {
+				t.Errorf("%q: got visual order %q, wanted none", c.s, ro.visual)
+			}
+			continue
+		}
+		if ro == nil {
+			t.Errorf("%q: got no visual order, wanted %q", c.s, c.visual)
+			continue
+		}
+		if ro.visual != c.visual || !reflect.DeepEqual(ro.vis, c.vis) {
+			t.Errorf("%q: got %q %v, wanted %q %v", c.s, ro.visual, ro.vis, c.visual, c.vis)
+		}
+	}
+}
+
+func TestBidiLayout(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("ab אב"))
+	left := ed.textLeft()
+
+	// drawn as "ab בא"; the caret is at the leading edge of each rune
+	for col, want := range []int{0, 7, 14, 35, 28, 21} {
+		if got := ed.getPixelsAbs(address.Simple{Col: col}).X - left; got != want {
+			t.Errorf("caret at column %d: got x=%d, wanted %d", col, got, want)
+		}
+	}
+
+	// hit-testing finds the logical column of the rune under the pointer,
+	// or the end of the line
+	for _, c := range []struct{ x, col int }{{3, 0}, {22, 4}, {30, 3}, {50, 5}} {
+		if got := ed.getAddress(image.Pt(left+c.x, 0)); got.Col != c.col {
+			t.Errorf("x=%d: got column %d, wanted %d", c.x, got.Col, c.col)
+		}
+	}
+
+	// selecting "b א" covers two discontiguous ranges
+	lay := ed.layoutLine(0, ed.segments(0)[0])
+	want := [][2]fixed.Int26_6{{fixed.I(7), fixed.I(21)}, {fixed.I(28), fixed.I(35)}}
+	if got := lay.ranges(1, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("got ranges %v, wanted %v", got, want)
+	}
+}
+
+func TestBidiDrawMirrored(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("(אב) cd"))
+	r := image.Rect(0, 0, 100, 20)
+
+	// "(אב) cd" is drawn as "cd (בא)", with its brackets mirrored
+	got := image.NewRGBA(r)
+	ed.drawLayout(got, image.ZP, "(אב) cd", ed.layoutLine(0, ed.segments(0)[0]), nil)
+	want := image.NewRGBA(r)
+	ed.drawString(want, image.ZP, "cd (בא)", nil)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("right-to-left text wasn't drawn as its visual order, with mirrored brackets")
+	}
+}
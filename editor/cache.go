@@ -0,0 +1,140 @@
+package editor
+
+import (
+	"image"
+	"image/draw"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// cacheSize bounds the number of entries in each of the font caches.
+// When it is exceeded, the cache is emptied.
+const cacheSize = 4096
+
+// fontCache holds measurements and rasterized glyphs of the Editor's
+// font. It is reset by SetFont.
+type fontCache struct {
+	lines    map[string][]fixed.Int26_6 // advances, by line content
+	advances map[advanceKey]fixed.Int26_6
+	faces    map[rune]fallback
+	glyphs   map[glyphKey]*glyph
+	bidi     map[string]*reordering // visual order, by line content
+	styles   []font.Face            // faces used by styles, by id
+}
+
+// Faces are identified in the cache by an id rather than by the
+// font.Face itself, which may not be comparable. The Editor's font has
+// id 0, fallback faces follow in order, and faces used by styles follow
+// those in the order they are first drawn.
+type glyphKey struct {
+	face   int // id of the face
+	r      rune
+	dx, dy fixed.Int26_6 // subpixel offset of the dot
+}
+
+type advanceKey struct {
+	face int // id of the face
+	r    rune
+}
+
+// glyph is a rasterized glyph, positioned relative to the integer
+// part of the dot it is drawn at.
+type glyph struct {
+	r    image.Rectangle
+	mask *image.Alpha
+}
+
+// subpixels is the number of horizontal subpixel positions at which
+// glyphs are rasterized.
+const subpixels = 4
+
+// uncached is the id of a face whose glyphs are not cached.
+const uncached = -1
+
+// glyph returns the glyph for fb.r in fb.face, as it would be drawn at
+// dot. If the face has no glyph for the rune, unicode.ReplacementChar or
+// '?' is used instead.
+func (fc *fontCache) glyph(fb fallback, dot fixed.Point26_6) *glyph {
+	face, r := fb.face, fb.r
+	key := glyphKey{
+		face: fb.id,
+		r:    r,
+		dx:   dot.X & 63 / (64 / subpixels) * (64 / subpixels),
+		dy:   dot.Y & 63,
+	}
+	if g, ok := fc.glyphs[key]; ok {
+		return g
+	}
+
+	origin := fixed.Point26_6{X: key.dx, Y: key.dy}
+	dr, mask, maskp, _, ok := face.Glyph(origin, r)
+	if !ok {
+		// try to draw unicode.ReplacementChar
+		dr, mask, maskp, _, ok = face.Glyph(origin, unicode.ReplacementChar)
+		if !ok {
+			// last ditch effort to draw something
+			dr, mask, maskp, _, ok = face.Glyph(origin, '?')
+			if !ok {
+				panic("couldn't draw glyph")
+			}
+		}
+	}
+
+	// the face may reuse mask, so it must be copied
+	g := &glyph{
+		r:    dr,
+		mask: image.NewAlpha(image.Rectangle{Max: dr.Size()}),
+	}
+	draw.Draw(g.mask, g.mask.Rect, mask, maskp, draw.Src)
+
+	if fb.id == uncached {
+		return g
+	}
+	if fc.glyphs == nil || len(fc.glyphs) >= cacheSize {
+		fc.glyphs = make(map[glyphKey]*glyph)
+	}
+	fc.glyphs[key] = g
+	return g
+}
+
+// styleFace returns the face used to draw r with the face of a style,
+// which is the face used without the style if it has no glyph for r.
+func (ed *Editor) styleFace(face font.Face, r rune) fallback {
+	if !hasGlyph(face, r) {
+		return ed.faceFor(r)
+	}
+	id := uncached
+	for i, f := range ed.fc.styles {
+		if sameFace(f, face) {
+			id = 1 + len(ed.fallbacks) + i
+			break
+		}
+	}
+	// a face which can't be compared, even with itself, isn't cached
+	if id == uncached && sameFace(face, face) {
+		id = 1 + len(ed.fallbacks) + len(ed.fc.styles)
+		ed.fc.styles = append(ed.fc.styles, face)
+	}
+	return fallback{face: face, id: id, r: r}
+}
+
+// sameFace reports whether f1 and f2 are the same face. Faces which
+// can't be compared are never the same: comparing them panics if their
+// type isn't comparable, or if it is a struct holding an uncomparable
+// value in an interface field.
+func sameFace(f1, f2 font.Face) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return f1 == f2
+}
+
+// draw draws g onto dst at dot using src.
+func (g *glyph) draw(dst draw.Image, dot fixed.Point26_6, src image.Image) {
+	dr := g.r.Add(image.Pt(dot.X.Floor(), dot.Y.Floor()))
+	draw.DrawMask(dst, dr, src, dr.Min, g.mask, image.ZP, draw.Over)
+}
@@ -1,12 +1,115 @@
 package editor
 
+import (
+	"strings"
+
+	"sigint.ca/clip"
+)
+
+// A Clipboard holds text which has been snarfed from an Editor, so that
+// it can be pasted. A *clip.Clipboard, which uses the system clipboard,
+// is a Clipboard.
+type Clipboard interface {
+	Get() ([]byte, error)
+	Put(b []byte) error
+}
+
+// MemClipboard is a Clipboard which holds text in memory, for use in
+// tests or to share snarfed text between Editors without using the system
+// clipboard. The zero value is an empty MemClipboard.
+type MemClipboard struct {
+	b []byte
+}
+
+// Get returns the contents of c.
+func (c *MemClipboard) Get() ([]byte, error) {
+	return append([]byte(nil), c.b...), nil
+}
+
+// Put replaces the contents of c with b.
+func (c *MemClipboard) Put(b []byte) error {
+	c.b = append([]byte(nil), b...)
+	return nil
+}
+
+// SetClipboard sets the Clipboard used to snarf and paste text. If c is
+// nil, the system clipboard is used.
+func (ed *Editor) SetClipboard(c Clipboard) {
+	if c == nil {
+		c = new(clip.Clipboard)
+	}
+	ed.clipboard = c
+}
+
+// SetPrimary sets a Clipboard to be used as the primary selection, as in
+// X11: text selected with the mouse is put into it, and the pastePrimary
+// action pastes its contents. If c is nil, which is the default, there
+// is no primary selection.
+func (ed *Editor) SetPrimary(c Clipboard) {
+	ed.primary = c
+}
+
 func (ed *Editor) snarf() {
-	ed.clipboard.Put([]byte(ed.buffer.GetSel(ed.dot)))
+	var s string
+	if len(ed.extra) > 0 {
+		var sels []string
+		for _, sel := range ed.Selections() {
+			sels = append(sels, ed.buffer.GetSel(sel))
+		}
+		s = strings.Join(sels, "\n")
+	} else {
+		s = ed.buffer.GetSel(ed.dot)
+	}
+	ed.clipboard.Put([]byte(s))
+	ed.rememberSnarf(s)
 }
 
 func (ed *Editor) paste() {
-	s, err := ed.clipboard.Get()
-	if err == nil {
-		ed.putString(string(s))
+	b, err := ed.clipboard.Get()
+	if err != nil {
+		return
+	}
+	// the clipboard may have been changed by another program
+	ed.rememberSnarf(string(b))
+	ed.put(string(b))
+}
+
+// put replaces each selection with s, and records where it was
+// inserted for a following pasteCycle.
+func (ed *Editor) put(s string) {
+	if len(ed.extra) > 0 {
+		ed.putAll(s, false)
+	} else {
+		ed.putString(s)
+	}
+	ed.pasted = ed.dot
+}
+
+// rememberSnarf adds s to the snarf history, unless it is already the
+// newest entry.
+func (ed *Editor) rememberSnarf(s string) {
+	if s == "" {
+		return
+	}
+	if top, ok := ed.snarfs.top(); ok && top == s {
+		return
+	}
+	ed.snarfs.push(s)
+}
+
+// pastePrimary pastes the contents of the primary selection, if any.
+func (ed *Editor) pastePrimary() {
+	if ed.primary == nil {
+		return
+	}
+	if b, err := ed.primary.Get(); err == nil {
+		ed.put(string(b))
+	}
+}
+
+// setPrimary puts the text of dot into the primary selection, if any.
+func (ed *Editor) setPrimary() {
+	if ed.primary != nil && !ed.dot.IsEmpty() {
+		ed.primary.Put([]byte(ed.buffer.GetSel(ed.dot)))
 	}
 }
@@ -0,0 +1,83 @@
+package editor
+
+import (
+	"image"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestClipboard(t *testing.T) {
+	clip := new(MemClipboard)
+	ed1 := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed2 := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed1.SetClipboard(clip)
+	ed2.SetClipboard(clip)
+
+	// text snarfed in one Editor is pasted in the other
+	ed1.Load([]byte("one two three"))
+	for _, sel := range []address.Selection{
+		{From: address.Simple{0, 0}, To: address.Simple{0, 3}},
+		{From: address.Simple{0, 4}, To: address.Simple{0, 7}},
+		{From: address.Simple{0, 8}, To: address.Simple{0, 13}},
+	} {
+		ed1.SetDot(sel)
+		ed1.SendKeyEvent(key.Event{Code: key.CodeC, Modifiers: key.ModMeta})
+	}
+	paste := key.Event{Code: key.CodeV, Modifiers: key.ModMeta}
+	cycle := key.Event{Code: key.CodeV, Modifiers: key.ModMeta | key.ModShift}
+	ed2.SendKeyEvent(paste)
+	if got := string(ed2.Contents()); got != "three" {
+		t.Errorf("got %q after paste, wanted %q", got, "three")
+	}
+
+	// the paste can be cycled through older entries, but only in the
+	// Editor which snarfed them
+	ed1.SetDot(address.Selection{From: ed1.LastAddress(), To: ed1.LastAddress()})
+	ed1.SendKeyEvent(paste)
+	for _, want := range []string{"two", "one", "three"} {
+		ed1.SendKeyEvent(cycle)
+		if got := string(ed1.Contents()); got != "one two three"+want {
+			t.Errorf("got %q after cycling, wanted %q", got, "one two three"+want)
+		}
+	}
+	ed1.SendUndo()
+	if got := string(ed1.Contents()); got != "one two threeone" {
+		t.Errorf("got %q after undo, wanted %q", got, "one two threeone")
+	}
+
+	// cycling only follows a paste
+	ed2.SendKeyEvent(key.Event{Code: key.CodeLeftArrow})
+	ed2.SendKeyEvent(cycle)
+	if got := string(ed2.Contents()); got != "three" {
+		t.Errorf("got %q after cycling without a paste, wanted %q", got, "three")
+	}
+}
+
+func TestPrimary(t *testing.T) {
+	primary := new(MemClipboard)
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.SetPrimary(primary)
+	ed.Load([]byte("one two"))
+	ed.SetDot(address.Selection{})
+
+	// sweep "one" with B1
+	y := ed.fontHeight / 2
+	x := func(col int) int { return ed.getPixelsAbs(address.Simple{0, col}).X }
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(0), y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(3), y), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(3), y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	if b, _ := primary.Get(); string(b) != "one" {
+		t.Errorf("got primary selection %q, wanted %q", b, "one")
+	}
+
+	ed.SetDot(address.Selection{From: ed.LastAddress(), To: ed.LastAddress()})
+	ed.SendKeyEvent(key.Event{Code: key.CodeInsert, Modifiers: key.ModShift})
+	if got := string(ed.Contents()); got != "one twoone" {
+		t.Errorf("got %q after pasting the primary selection, wanted %q", got, "one twoone")
+	}
+}
@@ -0,0 +1,384 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// Compose sequences are entered as in Plan 9: pressing and releasing Alt
+// starts a sequence, and the runes typed next are replaced by the rune
+// they compose. X followed by four hexadecimal digits composes the rune
+// with that code point. A sequence which matches nothing inserts the
+// runes typed, as does any other key which isn't graphic, and escape
+// abandons a sequence. Modifier keys such as shift are ignored.
+
+// A ComposeTable maps compose sequences to the runes they produce.
+type ComposeTable map[string]rune
+
+// Clone returns a copy of ct, which may be modified without affecting ct.
+func (ct ComposeTable) Clone() ComposeTable {
+	clone := make(ComposeTable, len(ct))
+	for seq, r := range ct {
+		clone[seq] = r
+	}
+	return clone
+}
+
+// DefaultComposeTable holds common compose sequences from the Plan 9
+// keyboard(6) table. It is used when OptionSet.Compose is nil.
+var DefaultComposeTable = ComposeTable{
+	// symbols
+	"!!":   '¡',
+	"c$":   '¢',
+	"l$":   '£',
+	"g$":   '¤',
+	"y$":   '¥',
+	"SS":   '§',
+	"\"\"": '¨',
+	"cO":   '©',
+	"sa":   'ª',
+	"<<":   '«',
+	"no":   '¬',
+	"rO":   '®',
+	"__":   '¯',
+	"de":   '°',
+	"+-":   '±',
+	"s2":   '²',
+	"s3":   '³',
+	"''":   '´',
+	"mi":   'µ',
+	"pg":   '¶',
+	"..":   '·',
+	",,":   '¸',
+	"s1":   '¹',
+	"s0":   'º',
+	">>":   '»',
+	"14":   '¼',
+	"12":   '½',
+	"34":   '¾',
+	"??":   '¿',
+	"mu":   '×',
+	"-:":   '÷',
+	// letters with diacritics
+	"`A":  'À',
+	"`a":  'à',
+	"`E":  'È',
+	"`e":  'è',
+	"`I":  'Ì',
+	"`i":  'ì',
+	"`N":  'Ǹ',
+	"`n":  'ǹ',
+	"`O":  'Ò',
+	"`o":  'ò',
+	"`U":  'Ù',
+	"`u":  'ù',
+	"'A":  'Á',
+	"'a":  'á',
+	"'C":  'Ć',
+	"'c":  'ć',
+	"'E":  'É',
+	"'e":  'é',
+	"'G":  'Ǵ',
+	"'g":  'ǵ',
+	"'I":  'Í',
+	"'i":  'í',
+	"'L":  'Ĺ',
+	"'l":  'ĺ',
+	"'N":  'Ń',
+	"'n":  'ń',
+	"'O":  'Ó',
+	"'o":  'ó',
+	"'R":  'Ŕ',
+	"'r":  'ŕ',
+	"'S":  'Ś',
+	"'s":  'ś',
+	"'U":  'Ú',
+	"'u":  'ú',
+	"'Y":  'Ý',
+	"'y":  'ý',
+	"'Z":  'Ź',
+	"'z":  'ź',
+	"^A":  'Â',
+	"^a":  'â',
+	"^C":  'Ĉ',
+	"^c":  'ĉ',
+	"^E":  'Ê',
+	"^e":  'ê',
+	"^G":  'Ĝ',
+	"^g":  'ĝ',
+	"^H":  'Ĥ',
+	"^h":  'ĥ',
+	"^I":  'Î',
+	"^i":  'î',
+	"^J":  'Ĵ',
+	"^j":  'ĵ',
+	"^O":  'Ô',
+	"^o":  'ô',
+	"^S":  'Ŝ',
+	"^s":  'ŝ',
+	"^U":  'Û',
+	"^u":  'û',
+	"^W":  'Ŵ',
+	"^w":  'ŵ',
+	"^Y":  'Ŷ',
+	"^y":  'ŷ',
+	"~A":  'Ã',
+	"~a":  'ã',
+	"~I":  'Ĩ',
+	"~i":  'ĩ',
+	"~N":  'Ñ',
+	"~n":  'ñ',
+	"~O":  'Õ',
+	"~o":  'õ',
+	"~U":  'Ũ',
+	"~u":  'ũ',
+	"\"A": 'Ä',
+	"\"a": 'ä',
+	"\"E": 'Ë',
+	"\"e": 'ë',
+	"\"I": 'Ï',
+	"\"i": 'ï',
+	"\"O": 'Ö',
+	"\"o": 'ö',
+	"\"U": 'Ü',
+	"\"u": 'ü',
+	"\"Y": 'Ÿ',
+	"\"y": 'ÿ',
+	"oA":  'Å',
+	"oa":  'å',
+	"oU":  'Ů',
+	"ou":  'ů',
+	",C":  'Ç',
+	",c":  'ç',
+	",E":  'Ȩ',
+	",e":  'ȩ',
+	",G":  'Ģ',
+	",g":  'ģ',
+	",K":  'Ķ',
+	",k":  'ķ',
+	",L":  'Ļ',
+	",l":  'ļ',
+	",N":  'Ņ',
+	",n":  'ņ',
+	",R":  'Ŗ',
+	",r":  'ŗ',
+	",S":  'Ş',
+	",s":  'ş',
+	",T":  'Ţ',
+	",t":  'ţ',
+	"vA":  'Ǎ',
+	"va":  'ǎ',
+	"vC":  'Č',
+	"vc":  'č',
+	"vD":  'Ď',
+	"vd":  'ď',
+	"vE":  'Ě',
+	"ve":  'ě',
+	"vG":  'Ǧ',
+	"vg":  'ǧ',
+	"vH":  'Ȟ',
+	"vh":  'ȟ',
+	"vI":  'Ǐ',
+	"vi":  'ǐ',
+	"vj":  'ǰ',
+	"vK":  'Ǩ',
+	"vk":  'ǩ',
+	"vL":  'Ľ',
+	"vl":  'ľ',
+	"vN":  'Ň',
+	"vn":  'ň',
+	"vO":  'Ǒ',
+	"vo":  'ǒ',
+	"vR":  'Ř',
+	"vr":  'ř',
+	"vS":  'Š',
+	"vs":  'š',
+	"vT":  'Ť',
+	"vt":  'ť',
+	"vU":  'Ǔ',
+	"vu":  'ǔ',
+	"vZ":  'Ž',
+	"vz":  'ž',
+	// other letters
+	"AE": 'Æ',
+	"ae": 'æ',
+	"OE": 'Œ',
+	"oe": 'œ',
+	"ss": 'ß',
+	"D-": 'Ð',
+	"d-": 'ð',
+	"|P": 'Þ',
+	"|p": 'þ',
+	"/O": 'Ø',
+	"/o": 'ø',
+	// Greek
+	"*a": 'α',
+	"*b": 'β',
+	"*g": 'γ',
+	"*d": 'δ',
+	"*e": 'ε',
+	"*z": 'ζ',
+	"*y": 'η',
+	"*h": 'θ',
+	"*i": 'ι',
+	"*k": 'κ',
+	"*l": 'λ',
+	"*m": 'μ',
+	"*n": 'ν',
+	"*c": 'ξ',
+	"*o": 'ο',
+	"*p": 'π',
+	"*r": 'ρ',
+	"*s": 'σ',
+	"*t": 'τ',
+	"*u": 'υ',
+	"*f": 'φ',
+	"*x": 'χ',
+	"*q": 'ψ',
+	"*w": 'ω',
+	"*A": 'Α',
+	"*B": 'Β',
+	"*G": 'Γ',
+	"*D": 'Δ',
+	"*E": 'Ε',
+	"*Z": 'Ζ',
+	"*Y": 'Η',
+	"*H": 'Θ',
+	"*I": 'Ι',
+	"*K": 'Κ',
+	"*L": 'Λ',
+	"*M": 'Μ',
+	"*N": 'Ν',
+	"*C": 'Ξ',
+	"*O": 'Ο',
+	"*P": 'Π',
+	"*R": 'Ρ',
+	"*S": 'Σ',
+	"*T": 'Τ',
+	"*U": 'Υ',
+	"*F": 'Φ',
+	"*X": 'Χ',
+	"*Q": 'Ψ',
+	"*W": 'Ω',
+	// mathematics
+	"<=": '≤',
+	">=": '≥',
+	"!=": '≠',
+	"~~": '≈',
+	"->": '→',
+	"<-": '←',
+	"fa": '∀',
+	"te": '∃',
+	"pd": '∂',
+	"el": '∈',
+	"sr": '√',
+	"if": '∞',
+}
+
+type composeState struct {
+	armed  bool   // Alt has been pressed, and no other key since
+	active bool   // a sequence is being typed
+	seq    []rune // the runes typed so far
+}
+
+func (ed *Editor) composeTable() ComposeTable {
+	if ed.opts.Compose != nil {
+		return ed.opts.Compose
+	}
+	return DefaultComposeTable
+}
+
+// composeKey handles e if it is part of a compose sequence, and reports
+// whether it did.
+func (ed *Editor) composeKey(e key.Event) bool {
+	if e.Code == key.CodeLeftAlt || e.Code == key.CodeRightAlt {
+		switch e.Direction {
+		case key.DirPress:
+			ed.compose.armed = !ed.opts.Vi || ed.vi.mode == viInsert
+		case key.DirRelease:
+			if ed.compose.armed {
+				ed.compose = composeState{active: true}
+			}
+		}
+		return true
+	}
+	if e.Direction == key.DirRelease {
+		return false
+	}
+	ed.compose.armed = false
+	if !ed.compose.active {
+		return false
+	}
+
+	if e.Rune < 0 && isModifier(e.Code) {
+		// e.g. shift, for an uppercase rune
+		return true
+	}
+	if e.Code == key.CodeEscape {
+		// abandon the sequence
+		ed.compose = composeState{}
+		return true
+	}
+	if e.Modifiers&(key.ModControl|key.ModMeta) != 0 || !isGraphic(e.Rune) {
+		// end the sequence with the runes typed so far, and handle e
+		// as usual
+		seq := string(ed.compose.seq)
+		ed.compose = composeState{}
+		if seq != "" {
+			ed.cancelPreedit()
+			ed.initTransformation()
+			ed.typeString(seq)
+			ed.dirty = true
+		}
+		return false
+	}
+	ed.compose.seq = append(ed.compose.seq, e.Rune)
+	if r, done := ed.composed(string(ed.compose.seq)); done {
+		ed.compose = composeState{}
+		ed.cancelPreedit()
+		ed.initTransformation()
+		ed.typeString(r)
+		ed.dirty = true
+	}
+	return true
+}
+
+// isModifier reports whether c is the code of a modifier key.
+func isModifier(c key.Code) bool {
+	switch c {
+	case key.CodeLeftShift, key.CodeRightShift,
+		key.CodeLeftControl, key.CodeRightControl,
+		key.CodeLeftAlt, key.CodeRightAlt,
+		key.CodeLeftGUI, key.CodeRightGUI:
+		return true
+	}
+	return false
+}
+
+// composed reports whether seq is a complete compose sequence, or cannot
+// be completed, and returns the text it produces.
+func (ed *Editor) composed(seq string) (string, bool) {
+	if strings.HasPrefix(seq, "X") {
+		hex := seq[1:]
+		if _, err := strconv.ParseUint(hex, 16, 32); hex != "" && err != nil {
+			return seq, true
+		}
+		if len(hex) < 4 {
+			return "", false
+		}
+		n, _ := strconv.ParseUint(hex, 16, 32)
+		return string(rune(n)), true
+	}
+	table := ed.composeTable()
+	if r, ok := table[seq]; ok {
+		return string(r), true
+	}
+	for s := range table {
+		if strings.HasPrefix(s, seq) {
+			return "", false
+		}
+	}
+	return seq, true
+}
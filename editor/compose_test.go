@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+// typeCompose types a compose sequence: alt, followed by the runes of seq.
+func typeCompose(ed *Editor, seq string) {
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirPress})
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirRelease})
+	for _, r := range seq {
+		e := key.Event{Rune: r}
+		if r == '\x1b' {
+			e.Code = key.CodeEscape
+		}
+		e.Direction = key.DirPress
+		ed.SendKeyEvent(e)
+		e.Direction = key.DirRelease
+		ed.SendKeyEvent(e)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	cases := []struct {
+		seq, want string
+	}{
+		{"oe", "œ"},
+		{"'e", "é"},
+		{"*l", "λ"},
+		{"X03bb", "λ"},
+		{"X03bbx", "λx"},
+		{"Xq", "Xq"},  // not hexadecimal
+		{"qq", "qq"},  // no such sequence
+		{"<=a", "≤a"}, // a sequence is complete when it matches
+		{"*\x1b", ""}, // escape abandons the sequence
+		{"", ""},
+	}
+	for _, c := range cases {
+		ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+		typeCompose(ed, c.seq)
+		if got := string(ed.Contents()); got != c.want {
+			t.Errorf("%q: got %q, wanted %q", c.seq, got, c.want)
+		}
+	}
+
+	// alt used as a modifier doesn't start a sequence
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirPress})
+	ed.SendKeyEvent(key.Event{Code: key.CodeRightArrow, Modifiers: key.ModAlt, Direction: key.DirPress})
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirRelease})
+	ed.SendKeyEvent(key.Event{Rune: 'o', Direction: key.DirPress})
+	ed.SendKeyEvent(key.Event{Rune: 'e', Direction: key.DirPress})
+	if got := string(ed.Contents()); got != "oe" {
+		t.Errorf("got %q after alt-arrow, wanted %q", got, "oe")
+	}
+
+	// shift is pressed as a key of its own for uppercase runes
+	for _, c := range []struct {
+		seq, want string
+	}{
+		{"X03BB", "λ"},
+		{"OE", "Œ"},
+	} {
+		ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+		ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirPress})
+		ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirRelease})
+		for _, r := range c.seq {
+			var mods key.Modifiers
+			shift := r >= 'A' && r <= 'Z'
+			if shift {
+				mods = key.ModShift
+				ed.SendKeyEvent(key.Event{Rune: -1, Code: key.CodeLeftShift, Direction: key.DirPress})
+			}
+			ed.SendKeyEvent(key.Event{Rune: r, Modifiers: mods, Direction: key.DirPress})
+			ed.SendKeyEvent(key.Event{Rune: r, Modifiers: mods, Direction: key.DirRelease})
+			if shift {
+				ed.SendKeyEvent(key.Event{Rune: -1, Code: key.CodeLeftShift, Direction: key.DirRelease})
+			}
+		}
+		if got := string(ed.Contents()); got != c.want {
+			t.Errorf("%q with shift: got %q, wanted %q", c.seq, got, c.want)
+		}
+	}
+
+	// a key which isn't graphic ends the sequence with the runes typed
+	ed = NewEditor(basicfont.Face7x13, SimpleTheme)
+	typeCompose(ed, "*")
+	ed.SendKeyEvent(key.Event{Rune: '\r', Code: key.CodeReturnEnter, Direction: key.DirPress})
+	if got := string(ed.Contents()); got != "*\n" {
+		t.Errorf("got %q after return, wanted %q", got, "*\n")
+	}
+
+	// the table can be overridden
+	opts := *SimpleTheme
+	opts.Compose = ComposeTable{"ok": '👍'}
+	ed = NewEditor(basicfont.Face7x13, &opts)
+	typeCompose(ed, "okoe")
+	if got := string(ed.Contents()); got != "👍oe" {
+		t.Errorf("got %q with a custom table, wanted %q", got, "👍oe")
+	}
+}
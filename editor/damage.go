@@ -0,0 +1,141 @@
+package editor
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"sigint.ca/graphics/editor/address"
+)
+
+// damage records the parts of the Editor which must be redrawn by the
+// next call to Draw.
+type damage struct {
+	all      bool
+	from, to int // damaged rows, if from < to
+}
+
+// rows records that rows [from, to) must be redrawn.
+func (d *damage) rows(from, to int) {
+	if d.from >= d.to {
+		d.from, d.to = from, to
+		return
+	}
+	if from < d.from {
+		d.from = from
+	}
+	if to > d.to {
+		d.to = to
+	}
+}
+
+// toEnd is used as the end of a damaged range which extends to the end
+// of the buffer and beyond, e.g. when rows have been inserted or deleted.
+const toEnd = math.MaxInt32
+
+// frame records the state of the Editor when it was last drawn.
+type frame struct {
+	dst       *image.RGBA
+	r         image.Rectangle
+	scrollPt  image.Point
+	textLeft  int
+	docHeight int
+	head      int // the row containing the cursor, for relative line numbers
+	sels      []address.Selection
+}
+
+// checkDamage compares the Editor to its state when it was last drawn,
+// recording any resulting damage, and reports whether the scrollbar
+// must be redrawn.
+func (ed *Editor) checkDamage(dst *image.RGBA, sels []address.Selection) bool {
+	f := frame{
+		dst:       dst,
+		r:         ed.r,
+		scrollPt:  ed.scrollPt(),
+		textLeft:  ed.textLeft(),
+		docHeight: ed.docHeight(),
+		head:      ed.head().Row,
+		sels:      sels,
+	}
+	last := ed.frame
+	ed.frame = f
+
+	if f.dst != last.dst || f.r != last.r || f.scrollPt != last.scrollPt || f.textLeft != last.textLeft {
+		ed.damage.all = true
+	}
+	if ed.opts.LineNumbers == RelativeLineNumbers && f.head != last.head {
+		ed.damage.all = true
+	}
+
+	// rows where selections or cursors have appeared or disappeared
+	for _, sel := range last.sels {
+		if !containsSel(sels, sel) {
+			ed.damage.rows(sel.From.Row, sel.To.Row+1)
+		}
+	}
+	for _, sel := range sels {
+		if !containsSel(last.sels, sel) {
+			ed.damage.rows(sel.From.Row, sel.To.Row+1)
+		}
+	}
+
+	return f.docHeight != last.docHeight
+}
+
+func containsSel(sels []address.Selection, sel address.Selection) bool {
+	for _, s := range sels {
+		if s == sel {
+			return true
+		}
+	}
+	return false
+}
+
+// drawDamaged redraws the damaged rows, and the scrollbar if sb is true,
+// and returns the rectangles which were redrawn.
+func (ed *Editor) drawDamaged(dst *image.RGBA, sels []address.Selection, sb bool) []image.Rectangle {
+	d := ed.damage
+	ed.damage = damage{}
+
+	var damaged []image.Rectangle
+	if sb && ed.opts.ScrollBar {
+		ed.drawSb(dst)
+		damaged = append(damaged, ed.sbRect())
+	}
+	if d.from >= d.to {
+		return damaged
+	}
+
+	from, to := ed.visibleRows()
+	if d.from > from {
+		from = d.from
+	}
+	if d.to < to {
+		to = d.to
+	}
+	left := ed.sbRect().Max.X
+	var band image.Rectangle
+	for row := from; row < to; row++ {
+		y := ed.getPixelsRel(address.Simple{Row: row}).Y
+		r := image.Rect(left, y, ed.r.Dx(), y+len(ed.lineStarts(row))*ed.fontHeight)
+		draw.Draw(dst, r, ed.opts.BG1, image.ZP, draw.Src)
+		ed.drawGutter(dst, row, row+1)
+		ed.drawRow(dst.SubImage(r.Intersect(ed.textRect())).(*image.RGBA), row, sels)
+		band = band.Union(r)
+	}
+
+	// clear any rows which have been deleted from the end of the buffer
+	if d.to > len(ed.buffer.Lines) {
+		y := ed.docHeight() + ed.fontHeight - ed.visible().Min.Y
+		if y < ed.r.Dy() {
+			r := image.Rect(left, y, ed.r.Dx(), ed.r.Dy())
+			draw.Draw(dst, r, ed.opts.BG1, image.ZP, draw.Src)
+			band = band.Union(r)
+		}
+	}
+
+	if !band.Empty() {
+		damaged = append(damaged, band)
+	}
+	return damaged
+}
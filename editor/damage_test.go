@@ -0,0 +1,63 @@
+package editor
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestDamage(t *testing.T) {
+	opts := *SimpleTheme
+	opts.ScrollBar = true
+	opts.LineNumbers = AbsoluteLineNumbers
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("one\ntwo\nthree\nfour\nfive\n"))
+	ed.SetDot(address.Selection{})
+
+	r := image.Rect(0, 0, 100, 100)
+	dst := image.NewRGBA(r)
+	if got := ed.Draw(dst, r); len(got) != 1 || got[0] != r {
+		t.Errorf("first Draw: got damage %v, wanted %v", got, r)
+	}
+
+	cases := []struct {
+		name  string
+		fn    func()
+		wantH int // the height of the damaged text, or -1 for any
+	}{
+		{"click", func() { ed.SetDot(address.Selection{From: address.Simple{1, 1}, To: address.Simple{1, 1}}) }, 2 * 13},
+		{"type", func() { ed.SendKeyEvent(key.Event{Rune: 'x'}) }, 13},
+		{"select", func() { ed.SetDot(address.Selection{From: address.Simple{1, 1}, To: address.Simple{2, 3}}) }, 2 * 13},
+		{"newline", func() { ed.SendKeyEvent(key.Event{Code: key.CodeReturnEnter}) }, -1},
+		{"delete", func() { ed.SendKeyEvent(key.Event{Code: key.CodeDeleteBackspace}) }, -1},
+		{"undo", func() { ed.SendUndo() }, -1},
+	}
+	for _, c := range cases {
+		c.fn()
+		damaged := ed.Draw(dst, r)
+		if c.wantH >= 0 {
+			var h int
+			for _, d := range damaged {
+				if d != ed.sbRect() {
+					h += d.Dy()
+				}
+			}
+			if h != c.wantH {
+				t.Errorf("%s: got damage %v, wanted a height of %d", c.name, damaged, c.wantH)
+			}
+		}
+
+		// compare against a complete redraw
+		want := image.NewRGBA(r)
+		ed.Draw(want, r)
+		if !bytes.Equal(dst.Pix, want.Pix) {
+			t.Errorf("%s: partial redraw differs from complete redraw", c.name)
+		}
+		ed.Draw(dst, r)
+	}
+}
@@ -0,0 +1,100 @@
+package editor
+
+import (
+	"time"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+// Text is dragged by pressing B1 inside dot and moving the pointer.
+// When B1 is released, the text is moved to the drop point, or copied
+// if a modifier key is held. While dragging, a cursor is drawn at the
+// drop point.
+
+// startDrag reports whether a B1 press at a should start dragging dot.
+func (ed *Editor) startDrag(a address.Simple) bool {
+	if len(ed.extra) > 0 || ed.dot.IsEmpty() || a.LessThan(ed.dot.From) || !a.LessThan(ed.dot.To) {
+		return false
+	}
+	ed.m.drag = true
+	ed.m.dragged = false
+	ed.m.drop = a
+	// if the pointer doesn't move, this is the first click of a
+	// double-click
+	ed.m.lastClickTime = time.Now()
+	return true
+}
+
+// dragTo moves the drop point to a, once the pointer has moved further
+// than a twitch from where the drag started.
+func (ed *Editor) dragTo(e mouse.Event) {
+	if !ed.m.dragged && isTwitch(ed.m.pt, ed.m.sweepOrigin, ed.px(twitch)) {
+		return
+	}
+	ed.m.dragged = true
+	oldScrollPt := ed.scrollPt()
+	ed.edgeScroll(e.Pos)
+	if ed.m.a != ed.m.drop || ed.scrollPt() != oldScrollPt {
+		ed.m.drop = ed.m.a
+		ed.dirty = true
+	}
+}
+
+// dropCaret returns sels, with a cursor at the drop point while
+// text is being dragged.
+func (ed *Editor) dropCaret(sels []address.Selection) []address.Selection {
+	if !ed.m.drag || !ed.m.dragged {
+		return sels
+	}
+	return append(sels, address.Selection{From: ed.m.drop, To: ed.m.drop})
+}
+
+// drop ends a drag with a B1 release. If the pointer never left the
+// selection's twitch, the release is treated as an ordinary click.
+func (ed *Editor) drop(e mouse.Event) {
+	sel, drop := ed.dot, ed.m.a
+	ed.m.drag = false
+	ed.dirty = true
+
+	if !ed.m.dragged {
+		ed.dot = address.Selection{From: ed.m.drop, To: ed.m.drop}
+		return
+	}
+	ed.m.lastClickTime = time.Time{}
+	if !drop.LessThan(sel.From) && !sel.To.LessThan(drop) {
+		// dropped onto itself
+		return
+	}
+
+	s := ed.buffer.GetSel(sel)
+	copying := e.Modifiers&(key.ModAlt|key.ModControl|key.ModMeta) != 0
+	if copying {
+		ed.initMulti(address.Selection{From: drop, To: drop})
+		end := ed.insertString(drop, s)
+		ed.multi.end = end
+		ed.dot = address.Selection{From: drop, To: end}
+		ed.commitTransformation()
+		return
+	}
+
+	// the move is recorded as a single transformation of the text
+	// spanning the selection and the drop point
+	if drop.LessThan(sel.From) {
+		ed.initMulti(address.Selection{From: drop, To: sel.To})
+		ed.clearSel(sel)
+		ed.multi.end = sel.From
+		end := ed.insertString(drop, s)
+		ed.multi.end = shiftAddr(ed.multi.end, drop, end)
+		ed.dot = address.Selection{From: drop, To: end}
+	} else {
+		ed.initMulti(address.Selection{From: sel.From, To: drop})
+		end := ed.insertString(drop, s)
+		ed.clearSel(sel)
+		ed.multi.end = shiftAddr(end, sel.To, sel.From)
+		ed.dot = address.Selection{From: shiftAddr(drop, sel.To, sel.From), To: ed.multi.end}
+	}
+	ed.commitTransformation()
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"image"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestDrag(t *testing.T) {
+	cases := []struct {
+		name  string
+		sel   address.Selection
+		drop  int // column
+		mods  key.Modifiers
+		want  string
+		moved address.Selection
+	}{
+		{"move forward", address.Selection{address.Simple{0, 0}, address.Simple{0, 3}}, 7, 0, " defabc", address.Selection{address.Simple{0, 4}, address.Simple{0, 7}}},
+		{"move back", address.Selection{address.Simple{0, 4}, address.Simple{0, 7}}, 0, 0, "defabc ", address.Selection{address.Simple{0, 0}, address.Simple{0, 3}}},
+		{"copy", address.Selection{address.Simple{0, 0}, address.Simple{0, 3}}, 7, key.ModAlt, "abc defabc", address.Selection{address.Simple{0, 7}, address.Simple{0, 10}}},
+		{"onto itself", address.Selection{address.Simple{0, 0}, address.Simple{0, 3}}, 2, 0, "abc def", address.Selection{address.Simple{0, 0}, address.Simple{0, 3}}},
+	}
+	for _, c := range cases {
+		ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+		ed.Load([]byte("abc def"))
+		ed.SetDot(c.sel)
+		ed.Draw(image.NewRGBA(image.Rect(0, 0, 200, 50)), image.Rect(0, 0, 200, 50))
+
+		y := ed.fontHeight / 2
+		x := func(col int) int { return ed.getPixelsAbs(address.Simple{0, col}).X + 1 }
+		ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(c.sel.From.Col+1), y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+		ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(c.drop), y), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+		if got := ed.dropCaret(nil); len(got) != 1 || got[0].From.Col != c.drop {
+			t.Errorf("%s: got drop cursor %v, wanted one at column %d", c.name, got, c.drop)
+		}
+		ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(c.drop), y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease, Modifiers: c.mods})
+
+		if got := string(ed.Contents()); got != c.want {
+			t.Errorf("%s: got %q, wanted %q", c.name, got, c.want)
+		}
+		if ed.dot != c.moved {
+			t.Errorf("%s: got dot %v, wanted %v", c.name, ed.dot, c.moved)
+		}
+		ed.SendUndo()
+		if got := string(ed.Contents()); got != "abc def" {
+			t.Errorf("%s: got %q after one undo, wanted %q", c.name, got, "abc def")
+		}
+	}
+
+	// a click inside the selection without moving places the cursor
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("abc def"))
+	pt := image.Pt(ed.getPixelsAbs(address.Simple{0, 2}).X+1, 1)
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	if want := (address.Selection{address.Simple{0, 2}, address.Simple{0, 2}}); ed.dot != want {
+		t.Errorf("got dot %v after a click, wanted %v", ed.dot, want)
+	}
+
+	// and counts as the first click of a double-click
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	if got := ed.GetDotContents(); got != "abc" {
+		t.Errorf("got dot %q after a double-click, wanted %q", got, "abc")
+	}
+}
+
+func TestDragAltCopy(t *testing.T) {
+	// alt held to copy dragged text doesn't start a compose sequence
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("abc def"))
+	ed.SetDot(address.Selection{address.Simple{0, 0}, address.Simple{0, 3}})
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 200, 50)), image.Rect(0, 0, 200, 50))
+	x := func(col int) int { return ed.getPixelsAbs(address.Simple{0, col}).X + 1 }
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(1), 1), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(7), 1), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x(7), 1), Button: mouse.ButtonLeft, Modifiers: key.ModAlt, Direction: mouse.DirRelease})
+	ed.SendKeyEvent(key.Event{Code: key.CodeLeftAlt, Direction: key.DirRelease})
+	ed.SendKeyEvent(key.Event{Rune: 'o', Direction: key.DirPress})
+	ed.SendKeyEvent(key.Event{Rune: 'e', Direction: key.DirPress})
+	if got, want := string(ed.Contents()), "abc defoe"; got != want {
+		t.Errorf("got %q after an alt-drag and typing, wanted %q", got, want)
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"image"
 	"image/draw"
 	"math"
-	"sort"
+	"strconv"
 
 	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/math/fixed"
 )
 
 // Draw draws the editor onto dst within the bounding rectangle dr, and returns
-// the height in pixels of the text that was drawn.
-func (ed *Editor) Draw(dst *image.RGBA, dr image.Rectangle) int {
+// the rectangles of dst which were changed. Only the parts of the editor which
+// have changed since the previous call to Draw are redrawn, unless dst or dr
+// are different, so the contents of dst must otherwise be left unchanged.
+func (ed *Editor) Draw(dst *image.RGBA, dr image.Rectangle) []image.Rectangle {
 	ed.dirty = false
 	return ed.draw(dst, dr)
 }
@@ -21,134 +25,272 @@ func (ed *Editor) Dirty() bool {
 	return ed.dirty
 }
 
-func (ed *Editor) draw(dst *image.RGBA, dr image.Rectangle) int {
+func (ed *Editor) draw(dst *image.RGBA, dr image.Rectangle) []image.Rectangle {
 	ed.r = dr
+	sels := ed.dropCaret(ed.preeditSels(ed.Selections()))
+	sbChanged := ed.checkDamage(dst, sels)
+
+	from, to := ed.visibleRows()
+	for row := from; row < to; row++ {
+		// highlighting may damage rows
+		ed.spans(row)
+	}
+
+	if !ed.damage.all {
+		return ed.drawDamaged(dst, sels, sbChanged)
+	}
+	ed.damage = damage{}
+
 	draw.Draw(dst, ed.r, ed.opts.BG1, image.ZP, draw.Src)
 	ed.drawSb(dst)
+	ed.drawGutter(dst, from, to)
 
-	from, to := ed.visibleRows()
+	// text which is scrolled horizontally must not be drawn over
+	// the scrollbar or gutter
+	dst = dst.SubImage(ed.textRect()).(*image.RGBA)
 	for row := from; row < to; row++ {
-		line := ed.buffer.Lines[row]
-
-		// draw selection rectangles
-		if !ed.dot.IsEmpty() && (row >= ed.dot.From.Row && row <= ed.dot.To.Row) {
-			// If some text has just been inserted (e.g. via paste from clipboard),
-			// it may not have been measured yet - selection rects need to be drawn
-			// before text. This only applies to when row == ed.dot.From.Row or
-			// row == ed.dot.To.Row (otherwise the entire line is selected and character
-			// advances are not necessary).
-			// TODO: only measure if line text has changed
-			if row == ed.dot.From.Row || row == ed.dot.To.Row {
-				ed.measureString(line.String())
-			}
-			ed.drawSelRect(dst, row)
+		ed.drawRow(dst, row, sels)
+	}
+	return []image.Rectangle{dr}
+}
+
+// drawRow draws the text of row, with its selections and cursors.
+func (ed *Editor) drawRow(dst *image.RGBA, row int, sels []address.Selection) {
+	spans := ed.spans(row)
+	for _, seg := range ed.segments(row) {
+		pt := ed.getPixelsRel(address.Simple{Row: row, Col: seg.start})
+		lay := ed.layoutLine(row, seg)
+		segSpans := sliceSpans(spans, seg.start, seg.end)
+		ed.drawSpanBGs(dst, pt, lay, segSpans)
+		for _, sel := range sels {
+			ed.drawSelRect(dst, sel, row, seg, pt, lay)
 		}
 
 		// draw font overtop
-		pt := ed.getPixelsRel(address.Simple{Row: row, Col: 0})
-		ed.drawString(dst, pt, line.String(), ed.opts.Text)
+		ed.drawLayout(dst, pt, seg.text, lay, segSpans)
+		ed.drawPreeditLine(dst, row, seg, pt, lay)
 	}
 
-	// draw cursor
-	if ed.dot.IsEmpty() {
+	// draw cursors
+	for _, sel := range sels {
+		if !sel.IsEmpty() || sel.From.Row != row {
+			continue
+		}
 		cursor := ed.opts.Cursor(ed.fontHeight)
-		pt := ed.getPixelsRel(ed.dot.From)
+		pt := ed.getPixelsRel(sel.From)
 		pt.X-- // match acme
 		draw.Draw(dst, cursor.Bounds().Add(pt), cursor, image.ZP, draw.Over)
 	}
-
-	return (to - from) * ed.fontHeight
 }
 
-func (ed *Editor) drawSelRect(dst *image.RGBA, row int) {
-	var r image.Rectangle
+// drawSelRect draws the part of the rectangle for sel which falls on
+// the display line seg of row, which is drawn at pt with layout lay.
+func (ed *Editor) drawSelRect(dst *image.RGBA, sel address.Selection, row int, seg segment, pt image.Point, lay layout) {
+	if sel.IsEmpty() || row < sel.From.Row || row > sel.To.Row {
+		return
+	}
+	last := seg.end == ed.buffer.Lines[row].RuneCount()
 
-	if row == ed.dot.From.Row {
-		r.Min = ed.getPixelsRel(ed.dot.From)
-	} else {
-		r.Min = ed.getPixelsRel(address.Simple{Row: row, Col: 0})
+	from, to := seg.start, seg.end
+	more := true // whether sel continues past the end of seg
+	if row == sel.From.Row {
+		if sel.From.Col > seg.end || sel.From.Col == seg.end && !last {
+			return
+		}
+		if sel.From.Col > from {
+			from = sel.From.Col
+		}
+	}
+	if row == sel.To.Row {
+		if sel.To.Col < seg.start {
+			return
+		}
+		if sel.To.Col <= seg.end {
+			to = sel.To.Col
+			more = false
+		}
 	}
 
-	if row == ed.dot.To.Row {
-		r.Max = ed.getPixelsRel(ed.dot.To)
-	} else {
-		r.Max = ed.getPixelsRel(address.Simple{Row: row, Col: 0})
-		r.Max.X = ed.r.Dx()
+	for _, x := range lay.ranges(from-seg.start, to-seg.start) {
+		r := image.Rect(pt.X+x[0].Round(), pt.Y, pt.X+x[1].Round(), pt.Y+ed.fontHeight)
+		draw.Draw(dst, r, ed.opts.Sel, image.ZP, draw.Src)
 	}
-	r.Max.Y += ed.fontHeight
+	if more {
+		r := image.Rect(pt.X+lay.width().Round(), pt.Y, ed.r.Dx(), pt.Y+ed.fontHeight)
+		draw.Draw(dst, r, ed.opts.Sel, image.ZP, draw.Src)
+	}
+}
 
-	draw.Draw(dst, r, ed.opts.Sel, image.ZP, draw.Src)
+// drawSpanBGs draws the backgrounds of any spans which have one, on
+// a display line drawn at pt with layout lay.
+func (ed *Editor) drawSpanBGs(dst *image.RGBA, pt image.Point, lay layout, spans []Span) {
+	for _, sp := range spans {
+		if sp.Style.BG == nil {
+			continue
+		}
+		for _, x := range lay.ranges(sp.Start, sp.End) {
+			r := image.Rect(pt.X+x[0].Round(), pt.Y, pt.X+x[1].Round(), pt.Y+ed.fontHeight)
+			draw.Draw(dst, r, sp.Style.BG, image.ZP, draw.Src)
+		}
+	}
 }
 
 func (ed *Editor) docHeight() int {
-	return (len(ed.buffer.Lines) - 1) * ed.fontHeight
+	return (ed.displayLines() - 1) * ed.fontHeight
 }
 
 func (ed *Editor) visible() image.Rectangle {
+	pt := ed.scrollPt()
 	return image.Rectangle{
-		Min: ed.scrollPt,
-		Max: ed.scrollPt.Add(ed.r.Size()),
+		Min: pt,
+		Max: pt.Add(ed.r.Size()),
 	}
 }
 
-func (ed *Editor) visibleRows() (from, to int) {
-	from = ed.visible().Min.Y / ed.fontHeight
-	to = ed.visible().Max.Y/ed.fontHeight + 2
-	if to > len(ed.buffer.Lines) {
-		to = len(ed.buffer.Lines)
+// scrollPt returns the scroll position in pixels.
+func (ed *Editor) scrollPt() image.Point {
+	row := ed.scrollRow
+	if last := len(ed.buffer.Lines) - 1; row > last {
+		row = last
 	}
+	y := ed.displayLine(row)*ed.fontHeight + int(math.Round(ed.scrollLine*float64(ed.fontHeight)))
+	return image.Pt(int(math.Round(ed.scrollCols*ed.colWidth())), y)
+}
+
+// setScrollPt sets the scroll position to pt, in pixels.
+func (ed *Editor) setScrollPt(pt image.Point) {
+	line := pt.Y / ed.fontHeight
+	if pt.Y < 0 {
+		line = 0
+	}
+	ed.scrollRow = ed.displayRow(line)
+	ed.scrollLine = float64(pt.Y-ed.displayLine(ed.scrollRow)*ed.fontHeight) / float64(ed.fontHeight)
+	ed.scrollCols = float64(pt.X) / ed.colWidth()
+}
+
+// colWidth returns the width of a column of horizontal scrolling in pixels.
+func (ed *Editor) colWidth() float64 {
+	if w := ed.digitwidth.Round(); w > 0 {
+		return float64(w)
+	}
+	return 1
+}
+
+func (ed *Editor) visibleRows() (from, to int) {
+	from = ed.displayRow(ed.visible().Min.Y / ed.fontHeight)
+	to = ed.displayRow(ed.visible().Max.Y/ed.fontHeight+1) + 1
 	return
 }
 
 func (ed *Editor) scroll(pt image.Point) {
-	ed.scrollPt.Y -= pt.Y
+	sp := ed.scrollPt().Sub(pt)
 
 	// check boundaries
-	if ed.visible().Min.Y < 0 {
-		ed.scrollPt.Y = 0
+	if sp.Y < 0 {
+		sp.Y = 0
 	}
-	max := ed.getPixelsAbs(address.Simple{Row: len(ed.buffer.Lines) - 1})
-	if ed.visible().Min.Y > max.Y {
-		ed.scrollPt.Y = max.Y
+	if max := ed.docHeight(); sp.Y > max {
+		sp.Y = max
 	}
+	ed.setScrollPt(sp)
+
+	if ed.wrapping() {
+		sp.X = 0
+	} else if pt.X != 0 {
+		if max := ed.maxScrollX(); sp.X > max {
+			sp.X = max
+		}
+	}
+	if sp.X < 0 {
+		sp.X = 0
+	}
+	ed.setScrollPt(sp)
+}
+
+// maxScrollX returns the largest horizontal scroll offset which
+// leaves some of the currently visible text in view.
+func (ed *Editor) maxScrollX() int {
+	var widest int
+	from, to := ed.visibleRows()
+	for row := from; row < to; row++ {
+		// lines aren't wrapped when scrolled horizontally
+		lay := ed.layoutLine(row, ed.segments(row)[0])
+		if w := lay.width().Round(); w > widest {
+			widest = w
+		}
+	}
+	max := widest - ed.textWidth()
+	if max < 0 {
+		return 0
+	}
+	return max
 }
 
 func (ed *Editor) autoscroll() {
 	visible := ed.visible()
 	pt := ed.getPixelsAbs(address.Simple{Row: ed.dot.From.Row})
-	if pt.Y > visible.Min.Y && pt.Y+ed.fontHeight < visible.Max.Y {
-		return
+	if pt.Y <= visible.Min.Y || pt.Y+ed.fontHeight >= visible.Max.Y {
+		ed.setScrollPt(image.Pt(visible.Min.X, pt.Y-int(.2*float64(visible.Dy()))))
+
+		// scroll fixes boundary conditions, since we manually set the
+		// scroll position
+		ed.scroll(image.ZP)
 	}
+	ed.scrollToColumn(ed.dot.To)
+}
 
-	ed.scrollPt.Y = pt.Y - int(.2*float64(visible.Dy()))
+// scrollToColumn scrolls horizontally the minimum distance required
+// to make the column containing a visible.
+func (ed *Editor) scrollToColumn(a address.Simple) {
+	if ed.r.Empty() || ed.wrapping() {
+		return
+	}
+	x := ed.getPixelsAbs(a).X - ed.textLeft()
+	sp := ed.scrollPt()
+	if x < sp.X {
+		ed.setScrollPt(image.Pt(x, sp.Y))
+	} else if x > sp.X+ed.textWidth() {
+		ed.setScrollPt(image.Pt(x-ed.textWidth(), sp.Y))
+	}
+}
 
-	// scroll fixes boundary conditions, since we manually set ed.scrollPt
+// scrollIntoView scrolls the minimum distance required to make the
+// row containing a visible.
+func (ed *Editor) scrollIntoView(a address.Simple) {
+	if ed.r.Empty() {
+		return
+	}
+	visible := ed.visible()
+	pt := ed.getPixelsAbs(a)
+	if pt.Y < visible.Min.Y {
+		ed.setScrollPt(image.Pt(visible.Min.X, pt.Y))
+	} else if pt.Y+ed.fontHeight > visible.Max.Y {
+		ed.setScrollPt(image.Pt(visible.Min.X, pt.Y+ed.fontHeight-visible.Dy()))
+	}
+	ed.scrollToColumn(a)
 	ed.scroll(image.ZP)
 }
 
 func (ed *Editor) getPixelsAbs(a address.Simple) image.Point {
 	var x, y int
-	s := ed.buffer.Lines[a.Row].String()
 
-	if len(s) == 0 {
+	// a display line is measured from its start, so that tabstops
+	// are relative to the start of the display line
+	starts := ed.lineStarts(a.Row)
+	k := lineOf(starts, a.Col)
+	col := a.Col - starts[k]
+
+	if ed.buffer.Lines[a.Row].RuneCount() == 0 {
 		// fast path
 		x = 0
 	} else {
-		adv := ed.measureString(s)
-		if a.Col >= len(adv) {
-			x = adv[len(adv)-1].Round()
-		} else {
-			x = adv[a.Col].Round()
-		}
+		seg := ed.segments(a.Row)[k]
+		x = ed.layoutLine(a.Row, seg).caret(col).Round()
 	}
 
-	y = a.Row * ed.fontHeight
+	y = (ed.displayLine(a.Row) + k) * ed.fontHeight
 
-	pt := image.Pt(x+ed.margin, y)
-	if ed.opts.ScrollBar {
-		pt.X += ed.sbwidth
-	}
-	return pt
+	return image.Pt(x+ed.textLeft(), y)
 }
 
 func (ed *Editor) getPixelsRel(a address.Simple) image.Point {
@@ -156,10 +298,7 @@ func (ed *Editor) getPixelsRel(a address.Simple) image.Point {
 }
 
 func (ed *Editor) getAddress(pt image.Point) address.Simple {
-	pt.X -= ed.margin
-	if ed.opts.ScrollBar {
-		pt.X -= ed.sbwidth
-	}
+	pt.X -= ed.textLeft()
 
 	// (0,0) if pt is above the buffer
 	if pt.Y < 0 {
@@ -167,33 +306,92 @@ func (ed *Editor) getAddress(pt image.Point) address.Simple {
 	}
 
 	var addr address.Simple
-	addr.Row = pt.Y / ed.fontHeight
+	d := pt.Y / ed.fontHeight
 
 	// end of the last line if addr is below the last line
-	if addr.Row > len(ed.buffer.Lines)-1 {
+	if d > ed.displayLines()-1 {
 		addr.Row = len(ed.buffer.Lines) - 1
 		addr.Col = ed.buffer.Lines[addr.Row].RuneCount()
 		return addr
 	}
 
-	adv := ed.measureString(ed.buffer.Lines[addr.Row].String())
-	// the column number is found by looking for the smallest px element
-	// which is larger than pt.X, and returning the column number before that.
-	// If no px elements are larger than pt.X, then return the last column on
-	// the line.
-	if len(adv) == 0 || pt.X <= adv[0].Round() {
-		addr.Col = 0
-	} else if pt.X > adv[len(adv)-1].Round() {
-		addr.Col = len(adv) - 1
-	} else {
-		n := sort.Search(len(adv), func(i int) bool {
-			return adv[i].Round() > pt.X+1
-		})
-		addr.Col = n - 1
+	addr.Row = ed.displayRow(d)
+	segs := ed.segments(addr.Row)
+	k := d - ed.displayLine(addr.Row)
+	seg := segs[k]
+
+	lay := ed.layoutLine(addr.Row, seg)
+	// the column is that of the rune containing pt.X, allowing a pixel of
+	// slop. Positions are compared in fixed point, as glyphs are drawn.
+	x := fixed.I(pt.X)
+	addr.Col = lay.col(x + fixed.I(1))
+	if n := len(lay.adv) - 1; addr.Col == n && x > lay.width() && k < len(segs)-1 {
+		// the last column is the start of the next display line
+		addr.Col--
 	}
+	addr.Col += seg.start
 	return addr
 }
 
+// textLeft returns the distance in pixels from the left edge of the
+// Editor to column 0 of the text.
+func (ed *Editor) textLeft() int {
+	x := ed.margin
+	if ed.opts.ScrollBar {
+		x += ed.sbwidth
+	}
+	return x + ed.gutterRect().Dx()
+}
+
+// textWidth returns the width in pixels of the area in which text is visible.
+func (ed *Editor) textWidth() int {
+	return ed.r.Dx() - ed.textLeft() - ed.margin
+}
+
+// textRect returns the rectangle in which text is drawn, including
+// the left margin.
+func (ed *Editor) textRect() image.Rectangle {
+	return image.Rect(ed.textLeft()-ed.margin, 0, ed.r.Dx(), ed.r.Dy())
+}
+
+// gutterRect returns the rectangle occupied by the line number gutter,
+// which is wide enough to hold the number of the last line.
+func (ed *Editor) gutterRect() image.Rectangle {
+	if ed.opts.LineNumbers == NoLineNumbers {
+		return image.ZR
+	}
+	digits := len(strconv.Itoa(len(ed.buffer.Lines)))
+	x := 0
+	if ed.opts.ScrollBar {
+		x = ed.sbwidth
+	}
+	width := (ed.digitwidth * fixed.Int26_6(digits)).Round() + ed.margin
+	return image.Rect(x, 0, x+width, ed.visible().Dy())
+}
+
+// drawGutter draws line numbers for rows [from, to) right-aligned in the gutter.
+func (ed *Editor) drawGutter(dst *image.RGBA, from, to int) {
+	gr := ed.gutterRect()
+	if gr.Empty() {
+		return
+	}
+	cur := ed.head().Row
+	for row := from; row < to; row++ {
+		n := row + 1
+		if ed.opts.LineNumbers == RelativeLineNumbers && row != cur {
+			n = row - cur
+			if n < 0 {
+				n = -n
+			}
+		}
+		s := strconv.Itoa(n)
+		adv := ed.measureString(s)
+		pt := ed.getPixelsRel(address.Simple{Row: row})
+		pt.X = gr.Max.X - adv[len(adv)-1].Round()
+		ed.drawString(dst, pt, s, []Span{{Start: 0, End: len(s), Style: Style{FG: ed.opts.BG2}}})
+	}
+}
+
 func (ed *Editor) sbRect() image.Rectangle {
 	if !ed.opts.ScrollBar {
 		return image.ZR
@@ -22,36 +22,86 @@ import (
 type Editor struct {
 	buffer *text.Buffer
 	dot    address.Selection
+	extra  []address.Selection // additional selections, besides dot
+	anchor address.Simple      // the fixed end of dot when it is extended from the keyboard
+
+	// the preferred pixel column for vertical cursor movement,
+	// valid while the cursor remains at a
+	sticky struct {
+		x int
+		a address.Simple
+	}
 
 	B2Action func(string) // define an action for the middle mouse button
 	B3Action func(string) // define an action for the right mouse button
 
+	// B2ArgAction defines an action for the B2-B1 chord, which is called
+	// with the text selected by B2 and, as an argument, the text which
+	// was selected with B1 before B2 was pressed. Dot is restored to the
+	// argument before B2ArgAction is called. If B2ArgAction is nil, the
+	// chord calls B2Action with the text selected by B2.
+	B2ArgAction func(cmd, arg string)
+
+	actions    map[string]Action // actions defined by SetAction
+	lastAction string            // the action performed by the previous key event
+
+	kills  ring              // the kill ring
+	yanked address.Selection // the text inserted by the last yank
+
 	opts *OptionSet
 
 	// drawing data
 	r          image.Rectangle
 	font       font.Face
 	fontHeight int
+	fallbacks  []font.Face   // faces for runes which font has no glyph for
+	fc         fontCache     // cached measurements and glyphs of font
 	tabwidth   fixed.Int26_6 // tab width in pixels
+	elastic    elasticCache
 
-	// TODO: overhaul everything to use points instead of pixels, and keep track of pixels per point
-	sbwidth int // scrollbar width; set by SetFont in order to scale with DPI
-	margin  int // margin width; set by SetFont in order to scale with DPI
+	// the scrollbar and margin scale with the font, unless the client
+	// has called SetPixelsPerPt, in which case they are sized in points
+	ppp     float32 // pixels per point; set by SetPixelsPerPt
+	ptSized bool    // whether SetPixelsPerPt has been called
+	sbwidth int     // scrollbar width in pixels
+	margin  int     // margin width in pixels
 
-	// TODO: this should be in points rather than pixels, DPI changes
-	// affect which part of the editor contents is visible
-	scrollPt image.Point
+	digitwidth fixed.Int26_6 // width of a line number digit; set by SetFont
 
-	dirty bool
+	// the scroll position is kept in rows and columns rather than pixels,
+	// so that the same text stays in view when its size changes
+	scrollRow  int     // the row at the top of the Editor
+	scrollLine float64 // the offset of the top from scrollRow, in lines
+	scrollCols float64 // the horizontal offset, in digit widths
 
-	m mouseState
+	dirty  bool
+	damage damage // the parts of the Editor to be redrawn
+	frame  frame  // the state of the Editor when it was last drawn
+
+	m       mouseState
+	vi      viState
+	compose composeState
+	hl      highlightCache
+	wrap    wrapCache
 
 	// history
 	history     *hist.History        // represents the Editor's history
 	savePoint   *hist.Transformation // records the last time the Editor was saved, for use by Saved and SetSaved
 	uncommitted *hist.Transformation // recent input which hasn't yet been committed to history
 
-	clipboard *clip.Clipboard // used for copy or paste events
+	// multi records an uncommitted edit of several selections, which
+	// spans from uncommitted.Pre.Sel.From to end.
+	multi struct {
+		active bool
+		end    address.Simple
+	}
+
+	clipboard Clipboard         // used for copy or paste events
+	primary   Clipboard         // the primary selection, if any
+	snarfs    ring              // recently snarfed or pasted text
+	pasted    address.Selection // the text inserted by the last paste
+
+	preedit preedit // the composition text of an input method
 }
 
 // NewEditor returns a new Editor with a clipping rectangle defined by size, a font face,
@@ -64,10 +114,12 @@ func NewEditor(face font.Face, opts *OptionSet) *Editor {
 		buffer: text.NewBuffer(),
 
 		dirty:     true,
+		ppp:       1,
 		opts:      opts,
 		history:   new(hist.History),
 		clipboard: new(clip.Clipboard),
 	}
+	ed.vi.enabled = opts.Vi
 	ed.SetFont(face)
 
 	return ed
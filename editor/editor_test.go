@@ -1,6 +1,8 @@
 package editor
 
 import (
+	"image"
+	"strings"
 	"testing"
 
 	"golang.org/x/image/font/basicfont"
@@ -78,3 +80,36 @@ func TestSetSaved(t *testing.T) {
 		t.Error("expected Saved=true, got Saved=false")
 	}
 }
+
+func TestPixelsPerPt(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte(strings.Repeat("a long line of text\n", 100)))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 100, 100)), image.Rect(0, 0, 100, 100))
+	ed.scroll(image.Pt(-2*7, -20*13-6))
+
+	// simulate moving to a screen with twice the pixel density
+	ed.SetPixelsPerPt(2)
+	face := &basicfont.Face{
+		Advance: 14, Width: 14, Height: 26, Ascent: 22, Descent: 4,
+		Mask: basicfont.Face7x13.Mask, Ranges: basicfont.Face7x13.Ranges,
+	}
+	ed.SetFont(face)
+
+	// the same text remains at the top left
+	if got, want := ed.scrollPt(), image.Pt(2*14, 20*26+12); got != want {
+		t.Errorf("got scroll offset %v, wanted %v", got, want)
+	}
+	if got, want := ed.margin, 8; got != want {
+		t.Errorf("got margin %d, wanted %d", got, want)
+	}
+
+	// without SetPixelsPerPt, decorations scale with the font
+	ed = NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.SetFont(face)
+	if got, want := ed.margin, 7; got != want {
+		t.Errorf("got margin %d with a larger font, wanted %d", got, want)
+	}
+	if got, want := ed.sbwidth, 21; got != want {
+		t.Errorf("got scrollbar width %d with a larger font, wanted %d", got, want)
+	}
+}
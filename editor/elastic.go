@@ -0,0 +1,182 @@
+package editor
+
+import (
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// elasticCache holds, for each row which has been measured with elastic
+// tabstops, the positions of its tabstops.
+type elasticCache map[int][]fixed.Int26_6
+
+// elasticPadding is the space left after the widest cell in a column,
+// in multiples of the width of the digit 0.
+const elasticPadding = 1
+
+// measureLine returns the offsets of runes in s, which is a display line
+// of row, as measure does with the faces of their styles, but using
+// elastic tabstops if they are enabled.
+func (ed *Editor) measureLine(row int, s string, faces []font.Face) []fixed.Int26_6 {
+	if !ed.opts.ElasticTabstops || ed.wrapping() || !strings.ContainsRune(s, '\t') {
+		if faces == nil {
+			return ed.measureString(s)
+		}
+		return ed.measure(s, faces, nil)
+	}
+	stops := ed.tabStops(row)
+	return ed.measure(s, faces, func(i int, x fixed.Int26_6) fixed.Int26_6 {
+		if i < len(stops) {
+			return stops[i]
+		}
+		return x + ed.tabwidth - x%ed.tabwidth
+	})
+}
+
+// measureVisual returns the offsets of the runes of s, a display line of
+// row, in the visual order ro. Elastic tabstops are found from the cells
+// of s in logical order, so each tab is as wide in ro as it is in s.
+func (ed *Editor) measureVisual(row int, s string, ro *reordering, faces []font.Face) []fixed.Int26_6 {
+	var visFaces []font.Face // the faces of the runes of ro
+	if faces != nil {
+		visFaces = make([]font.Face, len(faces))
+		for i, f := range faces {
+			visFaces[ro.vis[i]] = f
+		}
+	}
+	if !ed.opts.ElasticTabstops || ed.wrapping() || !strings.ContainsRune(s, '\t') {
+		if faces == nil {
+			return ed.measureString(ro.visual)
+		}
+		return ed.measure(ro.visual, visFaces, nil)
+	}
+	adv := ed.measureLine(row, s, faces)
+	var widths []fixed.Int26_6        // the width of each tab in s
+	tabOf := make([]int, len(ro.vis)) // the index in s of each tab
+	i := 0
+	for _, r := range s {
+		if r == '\t' {
+			tabOf[ro.vis[i]] = len(widths)
+			widths = append(widths, adv[i+1]-adv[i])
+		}
+		i++
+	}
+	var tabs []int // the index in s of each tab in ro
+	v := 0
+	for _, r := range ro.visual {
+		if r == '\t' {
+			tabs = append(tabs, tabOf[v])
+		}
+		v++
+	}
+	return ed.measure(ro.visual, visFaces, func(i int, x fixed.Int26_6) fixed.Int26_6 {
+		return x + widths[tabs[i]]
+	})
+}
+
+// tabStops returns the positions of the elastic tabstops of row.
+//
+// A cell is the text preceding each tab in a line. The cells in the same
+// column of a block of consecutive lines, each of which has a cell in
+// that column, are aligned: the column is as wide as its widest cell plus
+// padding, and at least as wide as a regular tab.
+func (ed *Editor) tabStops(row int) []fixed.Int26_6 {
+	if stops, ok := ed.elastic[row]; ok {
+		return stops
+	}
+
+	from, to := ed.tabBlock(row, row+1)
+
+	cells := make([][]fixed.Int26_6, to-from)
+	for i := range cells {
+		cells[i] = ed.cellWidths(ed.buffer.Lines[from+i].String())
+	}
+
+	padding := ed.digitwidth * elasticPadding
+	stops := make([][]fixed.Int26_6, len(cells))
+	for col, more := 0, true; more; col++ {
+		more = false
+		for i := 0; i < len(cells); {
+			if len(cells[i]) <= col {
+				i++
+				continue
+			}
+			more = true
+
+			// the run of lines with a cell in this column
+			width := ed.tabwidth
+			j := i
+			for ; j < len(cells) && len(cells[j]) > col; j++ {
+				if w := cells[j][col] + padding; w > width {
+					width = w
+				}
+			}
+			for ; i < j; i++ {
+				var start fixed.Int26_6
+				if col > 0 {
+					start = stops[i][col-1]
+				}
+				stops[i] = append(stops[i], start+width)
+			}
+		}
+	}
+
+	if ed.elastic == nil {
+		ed.elastic = make(elasticCache)
+	}
+	for i := range stops {
+		ed.elastic[from+i] = stops[i]
+	}
+	return ed.elastic[row]
+}
+
+// tabBlock returns the rows [from, to) including rows [row, end), and
+// any adjacent lines with tabs, whose tabstops may be aligned.
+func (ed *Editor) tabBlock(row, end int) (from, to int) {
+	hasTab := func(row int) bool {
+		return strings.ContainsRune(ed.buffer.Lines[row].String(), '\t')
+	}
+	from, to = row, end
+	for from > 0 && hasTab(from-1) {
+		from--
+	}
+	for to < len(ed.buffer.Lines) && hasTab(to) {
+		to++
+	}
+	return from, to
+}
+
+// elasticChanged records that n lines of the buffer starting at row
+// were replaced by m lines. A change to one cell can move the tabstops
+// of the neighbouring lines in its block, so their tabstops are
+// discarded and they are redrawn.
+func (ed *Editor) elasticChanged(row, n, m int) {
+	if ed.elastic == nil {
+		return
+	}
+	// the block includes any rows which were in the same block as the
+	// changed lines before the change
+	from, to := ed.tabBlock(row, row+m)
+	ec := make(elasticCache, len(ed.elastic))
+	for r, stops := range ed.elastic {
+		if r < from {
+			ec[r] = stops
+		} else if r >= to-m+n {
+			ec[r+m-n] = stops
+		}
+	}
+	ed.elastic = ec
+	ed.damage.rows(from, to)
+}
+
+// cellWidths returns the widths of the tab-terminated cells of s.
+func (ed *Editor) cellWidths(s string) []fixed.Int26_6 {
+	cells := strings.Split(s, "\t")
+	widths := make([]fixed.Int26_6, len(cells)-1)
+	for i := range widths {
+		adv := ed.measureString(cells[i])
+		widths[i] = adv[len(adv)-1]
+	}
+	return widths
+}
@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+func px(xs ...int) []fixed.Int26_6 {
+	adv := make([]fixed.Int26_6, len(xs))
+	for i, x := range xs {
+		adv[i] = fixed.I(x)
+	}
+	return adv
+}
+
+func TestTabWidth(t *testing.T) {
+	opts := *SimpleTheme
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	if got, want := ed.measureString("\tx"), px(0, 28, 35); !reflect.DeepEqual(got, want) {
+		t.Errorf("default tab width: got %v, wanted %v", got, want)
+	}
+
+	opts.TabWidth = 8
+	ed.SetOpts(&opts)
+	if got, want := ed.measureString("\tx"), px(0, 56, 63); !reflect.DeepEqual(got, want) {
+		t.Errorf("tab width 8: got %v, wanted %v", got, want)
+	}
+}
+
+func TestElasticTabstops(t *testing.T) {
+	opts := *SimpleTheme
+	opts.ElasticTabstops = true
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("a\tb\nabcdefgh\tc\n\nx\ty\tz"))
+
+	cases := []struct {
+		row  int
+		want []fixed.Int26_6
+	}{
+		// the first column of the first block is as wide as "abcdefgh" and padding
+		{0, px(0, 7, 63, 70)},
+		{1, px(0, 7, 14, 21, 28, 35, 42, 49, 56, 63, 70)},
+		// a separate block, with columns no narrower than a regular tab
+		{3, px(0, 7, 28, 35, 56, 63)},
+	}
+	for _, c := range cases {
+		got := ed.measureLine(c.row, ed.buffer.Lines[c.row].String(), nil)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("row %d: got %v, wanted %v", c.row, got, c.want)
+		}
+	}
+
+	// widening a cell moves the tabstops of the rest of its block, and
+	// only that block is redrawn
+	ed.damage = damage{}
+	ed.SetDot(address.Selection{From: address.Simple{Row: 1, Col: 8}, To: address.Simple{Row: 1, Col: 8}})
+	ed.typeAll("ij")
+	if ed.damage.all || ed.damage.from != 0 || ed.damage.to != 2 {
+		t.Errorf("after edit: got damage %+v, wanted rows [0, 2)", ed.damage)
+	}
+	if _, ok := ed.elastic[3]; !ok {
+		t.Error("after edit: tabstops of another block were discarded")
+	}
+	if got, want := ed.measureLine(0, "a\tb", nil), px(0, 7, 77, 84); !reflect.DeepEqual(got, want) {
+		t.Errorf("after edit: got %v, wanted %v", got, want)
+	}
+
+	// inserting a line moves the tabstops of following blocks
+	stops := ed.elastic[3]
+	ed.SetDot(address.Selection{})
+	ed.typeAll("\n")
+	if got := ed.elastic[4]; !reflect.DeepEqual(got, stops) {
+		t.Errorf("after inserting a line: got tabstops %v for row 4, wanted %v", got, stops)
+	}
+
+	// elastic tabstops are not used when wrapping
+	opts.Wrap = true
+	ed.SetOpts(&opts)
+	ed.r.Max.X = 1000
+	if got, want := ed.measureLine(0, "a\tb", nil), px(0, 7, 28, 35); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapped: got %v, wanted %v", got, want)
+	}
+}
+
+func TestElasticBidi(t *testing.T) {
+	opts := *SimpleTheme
+	opts.ElasticTabstops = true
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("abcdefgh\tx\nאב\tc"))
+
+	// the line is drawn as "c\tבא", and the tab is as wide as it is in
+	// logical order, where it follows the cell "אב"
+	lay := ed.layoutLine(1, ed.segments(1)[0])
+	if want := px(0, 7, 56, 63, 70); !reflect.DeepEqual(lay.adv, want) {
+		t.Errorf("got %v, wanted %v", lay.adv, want)
+	}
+}
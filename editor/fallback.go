@@ -0,0 +1,84 @@
+package editor
+
+import (
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// A GlyphChecker is a font.Face which can report whether it has a glyph
+// for r. Some faces, such as those provided by the freetype package, report
+// success for runes they have no glyph for, and draw a placeholder; they
+// must implement GlyphChecker for fallback faces to be used instead.
+type GlyphChecker interface {
+	HasGlyph(r rune) bool
+}
+
+// SetFallbackFonts sets an ordered list of faces used to draw runes
+// which the Editor's font has no glyph for. Each rune is drawn with the
+// first face which has a glyph for it. Line height is determined by
+// the Editor's font alone; glyphs from fallback faces are shifted
+// vertically to center them on the line.
+func (ed *Editor) SetFallbackFonts(faces ...font.Face) {
+	ed.fallbacks = faces
+	ed.fc = fontCache{}
+	ed.elastic = nil
+	ed.wrap = wrapCache{}
+	ed.damage.all = true
+	ed.dirty = true
+}
+
+// fallback is the face used to draw a rune.
+type fallback struct {
+	face font.Face
+	id   int           // identifies face in the fontCache
+	r    rune          // the rune to draw, which may be a substitute
+	dy   fixed.Int26_6 // offset of face's baseline from the Editor's font's
+}
+
+// faceFor returns the face used to draw r. If no face has a glyph for r,
+// unicode.ReplacementChar or '?' is substituted.
+func (ed *Editor) faceFor(r rune) fallback {
+	if fb, ok := ed.fc.faces[r]; ok {
+		return fb
+	}
+	fb, ok := ed.findFace(r)
+	if !ok {
+		fb, ok = ed.findFace(unicode.ReplacementChar)
+		if !ok {
+			fb, ok = ed.findFace('?')
+			if !ok {
+				panic("couldn't find a glyph")
+			}
+		}
+	}
+	if ed.fc.faces == nil || len(ed.fc.faces) >= cacheSize {
+		ed.fc.faces = make(map[rune]fallback)
+	}
+	ed.fc.faces[r] = fb
+	return fb
+}
+
+func (ed *Editor) findFace(r rune) (fallback, bool) {
+	if hasGlyph(ed.font, r) {
+		return fallback{face: ed.font, r: r}, true
+	}
+	pm := ed.font.Metrics()
+	for i, face := range ed.fallbacks {
+		if hasGlyph(face, r) {
+			fm := face.Metrics()
+			dy := ((fm.Ascent - fm.Descent) - (pm.Ascent - pm.Descent)) / 2
+			return fallback{face: face, id: i + 1, r: r, dy: dy}, true
+		}
+	}
+	return fallback{}, false
+}
+
+func hasGlyph(face font.Face, r rune) bool {
+	if gc, ok := face.(GlyphChecker); ok {
+		return gc.HasGlyph(r)
+	}
+	_, ok := face.GlyphAdvance(r)
+	return ok
+}
@@ -0,0 +1,120 @@
+package editor
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// wideFace returns a face with glyphs 14 pixels wide for runes [lo, hi).
+func wideFace(lo, hi rune) *basicfont.Face {
+	return &basicfont.Face{
+		Advance: 14, Width: 7, Height: 13, Ascent: 11, Descent: 2,
+		Mask:   basicfont.Face7x13.Mask,
+		Ranges: []basicfont.Range{{Low: lo, High: hi, Offset: 1}},
+	}
+}
+
+// noGlyphs is a face which claims to have no glyph for the runes in s.
+type noGlyphs struct {
+	*basicfont.Face
+	s string
+}
+
+func (f noGlyphs) HasGlyph(r rune) bool {
+	for _, c := range f.s {
+		if c == r {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFallbackFonts(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.SetFallbackFonts(wideFace('世', '世'+1))
+
+	// 界 isn't in any face, and is drawn as U+FFFD from the primary face
+	got := ed.measureString("a世界")
+	want := []fixed.Int26_6{0, fixed.I(7), fixed.I(21), fixed.I(28)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got advances %v, wanted %v", got, want)
+	}
+	if fb := ed.faceFor('世'); fb.dy != 0 {
+		t.Errorf("got baseline offset %v for a face with the same metrics, wanted 0", fb.dy)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	ed.drawString(dst, image.ZP, "a世界", nil)
+
+	ed.SetFont(noGlyphs{basicfont.Face7x13, "b"})
+	ed.SetFallbackFonts(wideFace('b', 'c'))
+	if got, want := ed.measureString("abc")[3], fixed.I(7+14+7); got != want {
+		t.Errorf("got width %v, wanted %v", got, want)
+	}
+}
+
+// sliceFace is a face of a type which is not comparable.
+type sliceFace struct {
+	*basicfont.Face
+	_ []int
+}
+
+// ifaceFace is a face of a comparable type, which holds a value that
+// is not comparable.
+type ifaceFace struct {
+	*basicfont.Face
+	v interface{}
+}
+
+func TestUncomparableFaces(t *testing.T) {
+	ed := NewEditor(sliceFace{Face: basicfont.Face7x13}, SimpleTheme)
+	ed.SetFallbackFonts(sliceFace{Face: wideFace('世', '世'+1)})
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	style := Style{Face: sliceFace{Face: basicfont.Face7x13}}
+	ed.drawString(dst, image.ZP, "a世", []Span{{Start: 0, End: 1, Style: style}})
+	ed.drawString(dst, image.ZP, "a世", []Span{{Start: 0, End: 1, Style: style}})
+	if got := ed.measureString("a世"); got[2] != fixed.I(21) {
+		t.Errorf("got width %v, wanted %v", got[2], fixed.I(21))
+	}
+
+	// a face of a comparable type holding an uncomparable value is drawn,
+	// but not cached
+	style = Style{Face: ifaceFace{Face: basicfont.Face7x13, v: []int{}}}
+	ed.drawString(dst, image.ZP, "a世", []Span{{Start: 0, End: 1, Style: style}})
+	ed.drawString(dst, image.ZP, "a世", []Span{{Start: 0, End: 1, Style: style}})
+	if n := len(ed.fc.styles); n != 0 {
+		t.Errorf("got %d cached style faces, wanted none", n)
+	}
+}
+
+// faceHighlighter styles the first two runes of each line with face.
+type faceHighlighter struct {
+	face *basicfont.Face
+}
+
+func (h faceHighlighter) Highlight(line string, state HighlightState) ([]Span, HighlightState) {
+	return []Span{{Start: 0, End: 2, Style: Style{Face: h.face}}}, nil
+}
+
+func TestStyleFaces(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("abcd"))
+	ed.SetHighlighter(faceHighlighter{wideFace('a', 'z')})
+	left := ed.textLeft()
+
+	// "ab" is measured in the wider face of its style, as it is drawn
+	if got, want := ed.getPixelsAbs(address.Simple{Col: 3}).X-left, 2*14+7; got != want {
+		t.Errorf("got x=%d for column 3, wanted %d", got, want)
+	}
+	if got := ed.getAddress(image.Pt(left+30, 0)); got.Col != 2 {
+		t.Errorf("got column %d at x=30, wanted 2", got.Col)
+	}
+	if got, want := ed.measureString("ab")[2], fixed.I(14); got != want {
+		t.Errorf("got width %v without a style, wanted %v", got, want)
+	}
+}
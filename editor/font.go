@@ -3,98 +3,215 @@ package editor
 import (
 	"image"
 	"image/draw"
-	"unicode"
 	"unicode/utf8"
 
 	"golang.org/x/image/font"
 	"golang.org/x/image/math/fixed"
 )
 
-// TODO: make this configurable
-const tabstop = 4
-
-// SetFont sets the Editor's font face to face.
+// SetFont sets the Editor's font face to face. The text at the top of the
+// Editor remains in view.
 func (ed *Editor) SetFont(face font.Face) {
 	if face == nil {
 		panic("nil font")
 	}
+	ed.setFont(face)
+}
+
+func (ed *Editor) setFont(face font.Face) {
 	ed.font = face
+	ed.fc = fontCache{}
 
 	ed.fontHeight = (face.Metrics().Ascent + face.Metrics().Descent).Round()
 	if ed.fontHeight == 0 {
 		panic("bad font")
 	}
 
-	// fix tabstop width
 	advance, ok := ed.font.GlyphAdvance('0')
 	if !ok {
 		panic("couldn't get glyph advance")
 	}
-	ed.tabwidth = advance * tabstop
+	ed.digitwidth = advance
+	if !ed.ptSized {
+		ed.sbwidth = ((advance * 3) / 2).Round()
+		ed.margin = (advance / 2).Round()
+	}
+	ed.setTabWidth()
+
+	ed.wrap = wrapCache{}
+	ed.damage.all = true
+	ed.dirty = true
+}
+
+// setTabWidth sets the width of tabstops from the font and options,
+// and discards measurements which depend on it.
+func (ed *Editor) setTabWidth() {
+	if ed.font == nil || ed.opts == nil {
+		// not yet initialized
+		return
+	}
+	n := ed.opts.TabWidth
+	if n <= 0 {
+		n = DefaultTabWidth
+	}
+	ed.tabwidth = ed.digitwidth * fixed.Int26_6(n)
+	ed.fc.lines = nil
+	ed.elastic = nil
+}
 
-	ed.sbwidth = ((advance * 3) / 2).Round()
-	ed.margin = (advance / 2).Round()
+// Sizes of the Editor's decorations, in points.
+const (
+	sbwidthPt = 12
+	marginPt  = 4
+)
 
+// SetPixelsPerPt sets the number of pixels per point of the screen the
+// Editor is drawn on. Once it has been called, the Editor's decorations
+// are sized in points rather than scaling with the font. The client
+// should also call SetFont with a face of the appropriate size. The text
+// at the top of the Editor remains in view.
+func (ed *Editor) SetPixelsPerPt(ppp float32) {
+	if ppp <= 0 {
+		panic("bad pixels per point")
+	}
+	ed.ppp = ppp
+	ed.ptSized = true
+	ed.sbwidth = ed.px(sbwidthPt)
+	ed.margin = ed.px(marginPt)
+	ed.wrap = wrapCache{}
+	ed.damage.all = true
 	ed.dirty = true
 }
 
-// drawLine draws s onto dst starting at pt.
-func (ed *Editor) drawString(dst draw.Image, pt image.Point, s string, src *image.Uniform) {
-	dot := fixed.P(pt.X, pt.Y)
-	dot.Y += ed.font.Metrics().Ascent
+// px returns the number of pixels in pt points.
+func (ed *Editor) px(pt float32) int {
+	return int(pt*ed.ppp + 0.5)
+}
+
+// drawString draws s onto dst starting at pt, styling runes according
+// to spans. Each glyph is drawn at the position given by measure, in the
+// face of its style, so that drawn text always matches hit-testing and
+// selections.
+func (ed *Editor) drawString(dst draw.Image, pt image.Point, s string, spans []Span) {
+	adv := ed.measureString(s)
+	if faces := spanFaces(spans, len(adv)-1); faces != nil {
+		adv = ed.measure(s, faces, nil)
+	}
+	ed.drawLayout(dst, pt, s, layout{adv: adv}, spans)
+}
 
-	// used to calculate tabstop locations (pt.X may not start from 0)
-	var width fixed.Int26_6
+// drawLayout is like drawString, but draws each rune of s at the
+// position given by lay, mirrored if it is right to left.
+func (ed *Editor) drawLayout(dst draw.Image, pt image.Point, s string, lay layout, spans []Span) {
+	origin := fixed.P(pt.X, pt.Y)
+	origin.Y += ed.font.Metrics().Ascent
 
+	var col, span int
 	for _, r := range s {
-		// handle tabstops
-		if r == '\t' {
-			off := ed.tabwidth - width%ed.tabwidth
-			dot.X += off
-			width += off
-			continue
+		if lay.rtl != nil && lay.rtl[col] {
+			r = mirror(r)
 		}
-		// try to draw the glyph
-		dr, mask, maskp, advance, ok := ed.font.Glyph(dot, r)
-		if !ok {
-			// try to draw unicode.ReplacementChar
-			dr, mask, maskp, advance, ok = ed.font.Glyph(dot, unicode.ReplacementChar)
-			if !ok {
-				// last ditch effort to draw something
-				dr, mask, maskp, advance, ok = ed.font.Glyph(dot, '?')
-				if !ok {
-					panic("couldn't draw glyph")
-				}
+		src, fb := ed.opts.Text, ed.faceFor(r)
+		if style, ok := spanAt(spans, &span, col); ok {
+			if style.FG != nil {
+				src = style.FG
 			}
+			if style.Face != nil {
+				fb = ed.styleFace(style.Face, r)
+			}
+		}
+		x := lay.left(col)
+		col++
+
+		if r == '\t' {
+			continue
 		}
-		draw.DrawMask(dst, dr, src, dr.Min, mask, maskp, draw.Over)
-		dot.X += advance
-		width += advance
+		dot := fixed.Point26_6{X: origin.X + x, Y: origin.Y + fb.dy}
+		ed.fc.glyph(fb, dot).draw(dst, dot, src)
 	}
 }
 
-// measureString returns a slice of monotonically increasing pixel offsets
-// for runes in s.
+// measureString returns a slice of monotonically increasing offsets for
+// runes in s: the ith element is the position at which the ith rune is
+// drawn, including any kerning between it and the previous rune, and the
+// last element is the width of s. The slice is cached, and must not be
+// modified.
 func (ed *Editor) measureString(s string) []fixed.Int26_6 {
+	if adv, ok := ed.fc.lines[s]; ok {
+		return adv
+	}
+	adv := ed.measure(s, nil, nil)
+	if ed.fc.lines == nil || len(ed.fc.lines) >= cacheSize {
+		ed.fc.lines = make(map[string][]fixed.Int26_6)
+	}
+	ed.fc.lines[s] = adv
+	return adv
+}
+
+// measure returns the offsets of runes in s, as measureString does.
+// The ith rune of s is measured in the face faces[i] of its style, if
+// faces is not nil and it has one. The ith tab in s, which starts at x,
+// ends at tab(i, x), or if tab is nil, at the next multiple of the tab
+// width.
+func (ed *Editor) measure(s string, faces []font.Face, tab func(i int, x fixed.Int26_6) fixed.Int26_6) []fixed.Int26_6 {
 	adv := make([]fixed.Int26_6, 1, utf8.RuneCountInString(s)+1)
+	var prev fallback
+	kern := false // whether prev is kerned with the next rune
+	var i, tabs int
 	for _, r := range s {
+		last := &adv[len(adv)-1]
 		// handle tabstops
 		if r == '\t' {
-			last := adv[len(adv)-1]
-			adv = append(adv, last+ed.tabwidth-last%ed.tabwidth)
+			if tab != nil {
+				adv = append(adv, tab(tabs, *last))
+			} else {
+				adv = append(adv, *last+ed.tabwidth-*last%ed.tabwidth)
+			}
+			tabs++
+			i++
+			kern = false
 			continue
 		}
-		advance, ok := ed.font.GlyphAdvance(r)
-		if !ok {
-			advance, ok = ed.font.GlyphAdvance(unicode.ReplacementChar)
-			if !ok {
-				advance, ok = ed.font.GlyphAdvance('?')
-				if !ok {
-					panic("couldn't get glyph advance")
-				}
-			}
+		fb := ed.faceFor(r)
+		if faces != nil && faces[i] != nil {
+			fb = ed.styleFace(faces[i], r)
+		}
+		if kern {
+			*last += ed.kern(prev, fb)
 		}
-		adv = append(adv, adv[len(adv)-1]+advance)
+		adv = append(adv, *last+ed.glyphAdvance(fb))
+		prev, kern = fb, true
+		i++
 	}
 	return adv
 }
+
+// kern returns the kerning adjustment between fb0.r and fb1.r, which is
+// zero if they are drawn with different faces, or with a face which
+// isn't cached.
+func (ed *Editor) kern(fb0, fb1 fallback) fixed.Int26_6 {
+	if fb0.id != fb1.id || fb0.id == uncached {
+		return 0
+	}
+	return fb0.face.Kern(fb0.r, fb1.r)
+}
+
+// glyphAdvance returns the advance of fb.r in fb.face.
+func (ed *Editor) glyphAdvance(fb fallback) fixed.Int26_6 {
+	key := advanceKey{face: fb.id, r: fb.r}
+	if advance, ok := ed.fc.advances[key]; ok {
+		return advance
+	}
+	advance, ok := fb.face.GlyphAdvance(fb.r)
+	if !ok {
+		panic("couldn't get glyph advance")
+	}
+	if fb.id == uncached {
+		return advance
+	}
+	if ed.fc.advances == nil || len(ed.fc.advances) >= cacheSize {
+		ed.fc.advances = make(map[advanceKey]fixed.Int26_6)
+	}
+	ed.fc.advances[key] = advance
+	return advance
+}
@@ -1,9 +1,16 @@
 package editor
 
 import (
+	"bytes"
+	"image"
+	"image/draw"
+	"reflect"
 	"testing"
 
+	"sigint.ca/graphics/editor/address"
+
 	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/math/fixed"
 
@@ -40,3 +47,85 @@ func BenchmarkMeasureString2(b *testing.B) {
 		advSink = font.MeasureString(face, s)
 	}
 }
+
+func TestGlyphCache(t *testing.T) {
+	face := basicfont.Face7x13
+	ed := NewEditor(face, SimpleTheme)
+	s := "the quick brown fox"
+
+	// draw twice, to make sure that cached glyphs are drawn correctly
+	got := image.NewRGBA(image.Rect(0, 0, 200, 20))
+	ed.drawString(got, image.ZP, s, nil)
+	draw.Draw(got, got.Rect, image.Transparent, image.ZP, draw.Src)
+	ed.drawString(got, image.ZP, s, nil)
+
+	want := image.NewRGBA(got.Rect)
+	d := font.Drawer{Dst: want, Src: image.Black, Face: face, Dot: fixed.P(0, face.Metrics().Ascent.Round())}
+	d.DrawString(s)
+
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("cached glyphs differ from those drawn by the face")
+	}
+}
+
+func BenchmarkDrawString(b *testing.B) {
+	ttf, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		b.Fatal(err)
+	}
+	face := truetype.NewFace(ttf, nil)
+	ed := NewEditor(face, SimpleTheme)
+	dst := image.NewRGBA(image.Rect(0, 0, 1000, 20))
+	s := "the quick brown fox jumps over the lazy dog. the quick brown fox jumps over the lazy dog."
+
+	for i := 0; i < b.N; i++ {
+		ed.drawString(dst, image.ZP, s, nil)
+	}
+}
+
+// kernFace is a face which kerns "AV" by -2 pixels.
+type kernFace struct {
+	*basicfont.Face
+}
+
+func (kernFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if r0 == 'A' && r1 == 'V' {
+		return fixed.I(-2)
+	}
+	return 0
+}
+
+func TestKerning(t *testing.T) {
+	face := kernFace{basicfont.Face7x13}
+	ed := NewEditor(face, SimpleTheme)
+	s := "AVA\tA"
+
+	got := ed.measureString(s)
+	want := []fixed.Int26_6{0, fixed.I(5), fixed.I(12), fixed.I(19), fixed.I(28), fixed.I(35)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got advances %v, wanted %v", got, want)
+	}
+
+	// glyphs are drawn where the face's Drawer would draw them
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	ed.drawString(dst, image.ZP, "AVA", nil)
+	wantDst := image.NewRGBA(dst.Rect)
+	d := font.Drawer{Dst: wantDst, Src: image.Black, Face: face, Dot: fixed.P(0, face.Metrics().Ascent.Round())}
+	d.DrawString("AVA")
+	if !bytes.Equal(dst.Pix, wantDst.Pix) {
+		t.Error("kerned glyphs differ from those drawn by the face")
+	}
+
+	// the caret and hit-testing use the kerned positions
+	ed.Load([]byte(s))
+	left := ed.textLeft()
+	if got := ed.getPixelsAbs(address.Simple{Col: 1}).X - left; got != 5 {
+		t.Errorf("got caret at x=%d, wanted 5", got)
+	}
+	if got := ed.getAddress(image.Pt(left+3, 0)); got.Col != 0 {
+		t.Errorf("got column %d at x=3, wanted 0", got.Col)
+	}
+	if got := ed.getAddress(image.Pt(left+6, 0)); got.Col != 1 {
+		t.Errorf("got column %d at x=6, wanted 1", got.Col)
+	}
+}
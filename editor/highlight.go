@@ -0,0 +1,157 @@
+package editor
+
+import (
+	"image"
+
+	"golang.org/x/image/font"
+)
+
+// A Style describes how a span of text is drawn. Nil fields take
+// their values from the Editor's OptionSet and font.
+type Style struct {
+	FG   *image.Uniform // the text colour
+	BG   *image.Uniform // the background colour
+	Face font.Face      // a variant of the Editor's font, such as bold or italic
+}
+
+// A Span applies a Style to the runes in columns [Start, End) of a line.
+type Span struct {
+	Start, End int
+	Style      Style
+}
+
+// A HighlightState is the state of a Highlighter at the end of a line,
+// such as whether a block comment is open. HighlightStates must be
+// comparable with ==, and the nil state is the state at the start of
+// the text.
+type HighlightState interface{}
+
+// A Highlighter divides lines of text into styled spans.
+type Highlighter interface {
+	// Highlight returns the spans for line, which begins in state, and
+	// the state at the end of line. The spans must be sorted and must
+	// not overlap; text outside of any span is drawn in the default
+	// style.
+	Highlight(line string, state HighlightState) ([]Span, HighlightState)
+}
+
+// SetHighlighter sets the Highlighter used to style the Editor's text.
+// If h is nil, all text is drawn in the OptionSet's Text colour.
+func (ed *Editor) SetHighlighter(h Highlighter) {
+	ed.hl = highlightCache{
+		h:     h,
+		lines: make([]highlightLine, len(ed.buffer.Lines)),
+	}
+	ed.damage.all = true
+	ed.dirty = true
+}
+
+// highlightCache holds the Highlighter's results for each line of
+// the buffer. Lines before from are up to date; later lines are
+// re-highlighted, as they are needed, if they have been modified or
+// the state at the end of the previous line has changed.
+type highlightCache struct {
+	h     Highlighter
+	lines []highlightLine
+	from  int
+}
+
+type highlightLine struct {
+	valid      bool
+	start, end HighlightState
+	spans      []Span
+}
+
+// changed records that n lines starting at row were replaced by m lines.
+func (hl *highlightCache) changed(row, n, m int) {
+	if hl.h == nil {
+		return
+	}
+	if n == m {
+		for i := row; i < row+m; i++ {
+			hl.lines[i].valid = false
+		}
+	} else {
+		lines := make([]highlightLine, len(hl.lines)-n+m)
+		copy(lines, hl.lines[:row])
+		copy(lines[row+m:], hl.lines[row+n:])
+		hl.lines = lines
+	}
+	if row < hl.from {
+		hl.from = row
+	}
+}
+
+// spans returns the styled spans for row, highlighting any preceding
+// lines that are out of date.
+func (ed *Editor) spans(row int) []Span {
+	hl := &ed.hl
+	if hl.h == nil {
+		return nil
+	}
+	for ; hl.from <= row; hl.from++ {
+		var start HighlightState
+		if hl.from > 0 {
+			start = hl.lines[hl.from-1].end
+		}
+		l := &hl.lines[hl.from]
+		if l.valid && l.start == start {
+			continue
+		}
+		l.spans, l.end = hl.h.Highlight(ed.buffer.Lines[hl.from].String(), start)
+		l.start, l.valid = start, true
+		ed.damage.rows(hl.from, hl.from+1)
+	}
+	return hl.lines[row].spans
+}
+
+// spanAt returns the style of the span containing col, advancing *i
+// past spans which end before col.
+func spanAt(spans []Span, i *int, col int) (Style, bool) {
+	for *i < len(spans) && spans[*i].End <= col {
+		*i++
+	}
+	if *i < len(spans) && spans[*i].Start <= col {
+		return spans[*i].Style, true
+	}
+	return Style{}, false
+}
+
+// spanFaces returns the face of the style of each of the n runes which
+// spans apply to, or nil if none of their styles has a face.
+func spanFaces(spans []Span, n int) []font.Face {
+	var faces []font.Face
+	for _, sp := range spans {
+		if sp.Style.Face == nil {
+			continue
+		}
+		if faces == nil {
+			faces = make([]font.Face, n)
+		}
+		for i := sp.Start; i < sp.End && i < n; i++ {
+			faces[i] = sp.Style.Face
+		}
+	}
+	return faces
+}
+
+// sliceSpans returns the parts of spans which fall within columns
+// [start, end), relative to start.
+func sliceSpans(spans []Span, start, end int) []Span {
+	var s []Span
+	for _, sp := range spans {
+		if sp.End <= start || sp.Start >= end {
+			continue
+		}
+		if sp.Start < start {
+			sp.Start = start
+		}
+		if sp.End > end {
+			sp.End = end
+		}
+		sp.Start -= start
+		sp.End -= start
+		s = append(s, sp)
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+// Package highlight provides syntax highlighters for use with
+// sigint.ca/graphics/editor.
+package highlight // import "sigint.ca/graphics/editor/highlight"
+
+import (
+	"go/scanner"
+	"go/token"
+	"image"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+
+	"sigint.ca/graphics/editor"
+)
+
+// Go is an editor.Highlighter for Go source code.
+type Go struct {
+	Keyword editor.Style
+	String  editor.Style // string and character literals
+	Comment editor.Style
+	Number  editor.Style
+}
+
+// NewGo returns a Go highlighter with colours chosen to suit the
+// editor package's themes.
+func NewGo() *Go {
+	return &Go{
+		Keyword: editor.Style{FG: image.NewUniform(color.RGBA{R: 0x00, G: 0x00, B: 0x99, A: 0xFF})},
+		String:  editor.Style{FG: image.NewUniform(color.RGBA{R: 0x99, G: 0x00, B: 0x00, A: 0xFF})},
+		Comment: editor.Style{FG: image.NewUniform(color.RGBA{R: 0x00, G: 0x66, B: 0x00, A: 0xFF})},
+		Number:  editor.Style{FG: image.NewUniform(color.RGBA{R: 0x66, G: 0x00, B: 0x99, A: 0xFF})},
+	}
+}
+
+// goState records a token which is continued from a previous line.
+type goState int
+
+const (
+	goNone goState = iota
+	goComment
+	goRawString
+)
+
+// Highlight implements editor.Highlighter.
+func (g *Go) Highlight(line string, state editor.HighlightState) ([]editor.Span, editor.HighlightState) {
+	var spans []editor.Span
+	col := func(off int) int { return utf8.RuneCountInString(line[:off]) }
+
+	// finish a token continued from the previous line
+	off := 0
+	switch state {
+	case goComment:
+		i := strings.Index(line, "*/")
+		if i < 0 {
+			return []editor.Span{{Start: 0, End: col(len(line)), Style: g.Comment}}, goComment
+		}
+		off = i + len("*/")
+		spans = append(spans, editor.Span{Start: 0, End: col(off), Style: g.Comment})
+	case goRawString:
+		i := strings.IndexByte(line, '`')
+		if i < 0 {
+			return []editor.Span{{Start: 0, End: col(len(line)), Style: g.String}}, goRawString
+		}
+		off = i + 1
+		spans = append(spans, editor.Span{Start: 0, End: col(off), Style: g.String})
+	}
+
+	src := []byte(line[off:])
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+
+	next := goState(goNone)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var style editor.Style
+		switch {
+		case tok == token.COMMENT:
+			style = g.Comment
+			if strings.HasPrefix(lit, "/*") && (len(lit) < 4 || !strings.HasSuffix(lit, "*/")) {
+				next = goComment
+			}
+		case tok == token.STRING || tok == token.CHAR:
+			style = g.String
+			if lit[0] == '`' && (len(lit) < 2 || !strings.HasSuffix(lit, "`")) {
+				next = goRawString
+			}
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			style = g.Number
+		case tok.IsKeyword():
+			style = g.Keyword
+		default:
+			continue
+		}
+		start := off + file.Offset(pos)
+		end := start + len(lit)
+		if next != goNone {
+			// unterminated tokens continue to the end of the line
+			end = len(line)
+		}
+		spans = append(spans, editor.Span{Start: col(start), End: col(end), Style: style})
+	}
+	return spans, next
+}
@@ -0,0 +1,43 @@
+package highlight
+
+import (
+	"testing"
+
+	"sigint.ca/graphics/editor"
+)
+
+func TestGo(t *testing.T) {
+	g := NewGo()
+	type span struct {
+		start, end int
+		style      editor.Style
+	}
+	lines := []struct {
+		line  string
+		spans []span
+	}{
+		{"func f() int { return 0x1F } // done", []span{{0, 4, g.Keyword}, {15, 21, g.Keyword}, {22, 26, g.Number}, {29, 36, g.Comment}}},
+		{`s := "héllo" + 'x'`, []span{{5, 12, g.String}, {15, 18, g.String}}},
+		{"x := `raw", []span{{5, 9, g.String}}},
+		{"still raw", []span{{0, 9, g.String}}},
+		{"end` /* comment", []span{{0, 4, g.String}, {5, 15, g.Comment}}},
+		{"go on", []span{{0, 5, g.Comment}}},
+		{"*/ for", []span{{0, 2, g.Comment}, {3, 6, g.Keyword}}},
+	}
+
+	var state editor.HighlightState
+	for i, l := range lines {
+		var spans []editor.Span
+		spans, state = g.Highlight(l.line, state)
+		if len(spans) != len(l.spans) {
+			t.Errorf("line %d: got %d spans, wanted %d: %v", i, len(spans), len(l.spans), spans)
+			continue
+		}
+		for j, sp := range spans {
+			want := l.spans[j]
+			if sp.Start != want.start || sp.End != want.end || sp.Style != want.style {
+				t.Errorf("line %d, span %d: got [%d,%d), wanted [%d,%d)", i, j, sp.Start, sp.End, want.start, want.end)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package editor
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+// commentHighlighter highlights text between "(*" and "*)", which may
+// span lines, and counts the lines it has highlighted.
+type commentHighlighter struct {
+	n int
+}
+
+func (h *commentHighlighter) Highlight(line string, state HighlightState) ([]Span, HighlightState) {
+	h.n++
+	var spans []Span
+	in := state == true
+	col, start := 0, 0
+	for i := range line {
+		if !in && strings.HasPrefix(line[i:], "(*") {
+			in, start = true, col
+		} else if in && strings.HasPrefix(line[i:], "*)") {
+			in = false
+			spans = append(spans, Span{Start: start, End: col + 2, Style: Style{FG: image.White}})
+		}
+		col++
+	}
+	if in {
+		spans = append(spans, Span{Start: start, End: col, Style: Style{FG: image.White}})
+	}
+	return spans, in
+}
+
+func TestHighlightIncremental(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte(strings.Repeat("line of text\n", 100)))
+	h := new(commentHighlighter)
+	ed.SetHighlighter(h)
+
+	last := len(ed.buffer.Lines) - 1
+	ed.spans(last)
+	if h.n != last+1 {
+		t.Errorf("got %d lines highlighted, wanted %d", h.n, last+1)
+	}
+
+	// changing a line without changing its end state only requires
+	// that line to be highlighted again
+	h.n = 0
+	ed.SetDot(address.Selection{From: address.Simple{50, 0}, To: address.Simple{50, 4}})
+	ed.Replace("more")
+	ed.spans(last)
+	if h.n != 1 {
+		t.Errorf("got %d lines highlighted, wanted 1", h.n)
+	}
+
+	// opening a comment affects every following line
+	h.n = 0
+	ed.SetDot(address.Selection{From: address.Simple{50, 0}, To: address.Simple{50, 0}})
+	ed.Replace("(*\n")
+	last++
+	ed.spans(last)
+	if h.n != last-50+1 {
+		t.Errorf("got %d lines highlighted, wanted %d", h.n, last-50+1)
+	}
+	spans := ed.spans(51)
+	if len(spans) != 1 || spans[0].Start != 0 || spans[0].End != 12 {
+		t.Errorf("got spans %v for line 51, wanted one span covering the line", spans)
+	}
+
+	// lines which move as the result of a deletion keep their spans
+	h.n = 0
+	ed.SetDot(address.Selection{From: address.Simple{10, 0}, To: address.Simple{11, 0}})
+	ed.Replace("")
+	ed.spans(last - 1)
+	if h.n != 1 {
+		t.Errorf("got %d lines highlighted, wanted 1", h.n)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 // SendUndo attempts to apply the Editor's previous history state, if it exists.
 func (ed *Editor) SendUndo() {
+	ed.cancelPreedit()
 	// commit any lingering uncommitted changes
 	ed.initTransformation()
 	ed.commitTransformation()
@@ -15,6 +16,7 @@ func (ed *Editor) SendUndo() {
 
 // SendRedo attempts to apply the Editor's next history state, if it exists.
 func (ed *Editor) SendRedo() {
+	ed.cancelPreedit()
 	// commit any lingering uncommitted changes
 	ed.initTransformation()
 	ed.commitTransformation()
@@ -24,7 +26,7 @@ func (ed *Editor) SendRedo() {
 // CanUndo reports whether the Editor has a previous history state which can be applied.
 func (ed *Editor) CanUndo() bool {
 	return ed.history.CanUndo() ||
-		ed.uncommitted != nil && len(ed.uncommitted.Post.Text) > 0
+		ed.uncommitted != nil && (len(ed.uncommitted.Post.Text) > 0 || ed.multi.active)
 }
 
 // CanRedo reports whether the Editor has a following history state which can be applied.
@@ -46,7 +48,7 @@ func (ed *Editor) SetSaved() {
 // time SetSaved was called.
 func (ed *Editor) Saved() bool {
 	return ed.history.Current() == ed.savePoint &&
-		(ed.uncommitted == nil || ed.uncommitted.Post.Text == "")
+		(ed.uncommitted == nil || ed.uncommitted.Post.Text == "" && !ed.multi.active)
 }
 
 func (ed *Editor) undo() {
@@ -54,6 +56,7 @@ func (ed *Editor) undo() {
 	if !ok {
 		return
 	}
+	ed.extra = nil
 	ed.dot = ch.Sel
 	ed.putString(ch.Text)
 	ed.dirty = true
@@ -64,6 +67,7 @@ func (ed *Editor) redo() {
 	if !ok {
 		return
 	}
+	ed.extra = nil
 	ed.dot = ch.Sel
 	ed.putString(ch.Text)
 	ed.dirty = true
@@ -91,7 +95,11 @@ func (ed *Editor) commitTransformation() {
 		return
 	}
 
-	if ed.uncommitted.Post.Text == "" {
+	if ed.multi.active {
+		ed.uncommitted.Post.Sel = address.Selection{ed.uncommitted.Pre.Sel.From, ed.multi.end}
+		ed.uncommitted.Post.Text = ed.buffer.GetSel(ed.uncommitted.Post.Sel)
+		ed.multi.active = false
+	} else if ed.uncommitted.Post.Text == "" {
 		ed.uncommitted.Post.Text = ed.buffer.GetSel(ed.dot)
 		ed.uncommitted.Post.Sel = ed.dot
 	} else {
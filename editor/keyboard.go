@@ -1,7 +1,6 @@
 package editor
 
 import (
-	"image"
 	"unicode"
 	"unicode/utf8"
 
@@ -16,130 +15,106 @@ func (ed *Editor) SendKeyEvent(e key.Event) {
 }
 
 func (ed *Editor) handleKeyEvent(e key.Event) {
+	if ed.composeKey(e) {
+		return
+	}
 	if e.Direction == key.DirRelease {
 		// ignore key up events
 		return
 	}
 
+	ed.cancelPreedit()
 	ed.dirty = true
 
+	if ed.opts.Vi && ed.viKey(e) {
+		return
+	}
+
 	// prepare for a change in the editor's history.
 	ed.initTransformation()
 
-	switch {
-	case e.Code == key.CodeEscape:
-		if ed.dot.IsEmpty() {
-			ed.dot.From.Col -= utf8.RuneCountInString(ed.uncommitted.Post.Text)
-		} else {
-			ed.dot = ed.buffer.ClearSel(ed.dot)
-		}
-		ed.commitTransformation()
+	if name, fn, ok := ed.lookupKey(e); ok {
+		fn(ed)
+		ed.lastAction = name
+		return
+	}
+	ed.lastAction = ""
 
-	case e.Code == key.CodeDeleteBackspace, e.Modifiers == key.ModControl && e.Code == key.CodeH:
-		ed.backspace(1)
-		ed.commitTransformation()
+	if isGraphic(e.Rune) && e.Modifiers&key.ModMeta == 0 {
+		ed.typeString(string(e.Rune))
+	}
+}
 
-	// word kill
-	case e.Modifiers == key.ModControl && e.Code == key.CodeW:
-		if ed.dot.From.Col == 0 {
-			ed.backspace(1)
-		} else {
-			line := []rune(ed.buffer.Lines[ed.dot.From.Row].String())
-			var n, dot int
-			for dot = ed.dot.From.Col; dot > 0 && !isWordChar(line[dot-1]); dot-- {
-				n++
-			}
-			for ; dot > 0 && isWordChar(line[dot-1]); dot-- {
-				n++
-			}
-			ed.backspace(n)
-		}
-		ed.commitTransformation()
+// typeString inserts s at each selection, as typed input. The
+// transformation must have been initialized.
+func (ed *Editor) typeString(s string) {
+	if len(ed.extra) > 0 {
+		ed.typeAll(s)
+		ed.scrollIntoView(ed.dot.To)
+		return
+	}
+	ed.uncommitted.Post.Text += s
+	ed.putString(s)
+	ed.dot.From = ed.dot.To
+	ed.scrollIntoView(ed.dot.To)
 
-	// line kill
-	case e.Modifiers == key.ModControl && e.Code == key.CodeU:
-		if ed.dot.From.Col == 0 {
-			ed.backspace(1)
-		} else {
-			ed.backspace(ed.dot.From.Col)
-		}
-		ed.commitTransformation()
+	// don't commit - history is not updated for each rune of input
+}
 
-	case e.Code == key.CodeReturnEnter, e.Modifiers == key.ModControl && e.Code == key.CodeJ:
-		prefix := ""
-		if ed.opts.AutoIndent {
-			prefix = ed.getIndentation()
-		}
-		ed.putString("\n" + prefix)
-		ed.dot.From = ed.dot.To
-		ed.uncommitted.Post.Text += "\n"
-		ed.commitTransformation()
-
-	case e.Code == key.CodeUpArrow:
-		ed.scroll(image.Pt(0, 18*ed.fontHeight))
-		ed.commitTransformation()
-
-	case e.Code == key.CodeDownArrow:
-		ed.scroll(image.Pt(0, -18*ed.fontHeight))
-		ed.commitTransformation()
-
-	case e.Code == key.CodeLeftArrow:
-		ed.commitTransformation()
-		a := ed.buffer.PrevSimple(ed.dot.From)
-		ed.dot.From, ed.dot.To = a, a
-
-	case e.Code == key.CodeRightArrow:
-		ed.commitTransformation()
-		a := ed.buffer.NextSimple(ed.dot.To)
-		ed.dot.From, ed.dot.To = a, a
-
-	case e.Modifiers == key.ModControl && e.Code == key.CodeA:
-		ed.commitTransformation()
-		ed.dot.From.Col = 0
-		ed.dot.To = ed.dot.From
-
-	case e.Modifiers == key.ModControl && e.Code == key.CodeE:
-		ed.commitTransformation()
-		ed.dot.From.Col = ed.buffer.Lines[ed.dot.From.Row].RuneCount()
-		ed.dot.To = ed.dot.From
-
-	case e.Modifiers == key.ModMeta && e.Code == key.CodeC:
-		ed.commitTransformation()
-		ed.snarf()
-
-	case e.Modifiers == key.ModMeta && e.Code == key.CodeV:
-		ed.paste()
-		ed.commitTransformation()
-
-	case e.Modifiers == key.ModMeta && e.Code == key.CodeX:
-		ed.snarf()
-		ed.dot = ed.buffer.ClearSel(ed.dot)
-		ed.commitTransformation()
-
-	case e.Modifiers == key.ModMeta && e.Code == key.CodeA:
-		ed.commitTransformation()
-		last := len(ed.buffer.Lines) - 1
-		ed.dot.From = address.Simple{0, 0}
-		ed.dot.To = address.Simple{last, ed.buffer.Lines[last].RuneCount()}
-
-	case e.Modifiers == key.ModMeta|key.ModShift && e.Code == key.CodeZ:
-		ed.commitTransformation()
-		ed.redo()
-
-	case e.Modifiers == key.ModMeta && e.Code == key.CodeZ:
-		ed.commitTransformation()
-		ed.undo()
-
-	default:
-		if isGraphic(e.Rune) && e.Modifiers&key.ModMeta == 0 {
-			s := string(e.Rune)
-			ed.uncommitted.Post.Text += s
-			ed.putString(s)
-			ed.dot.From = ed.dot.To
-
-			// don't commit - history is not updated for each rune of input
-		}
+// head returns the end of dot which moves when dot is extended from
+// the keyboard; the other end is the anchor.
+func (ed *Editor) head() address.Simple {
+	if !ed.dot.IsEmpty() && ed.anchor == ed.dot.To {
+		return ed.dot.From
+	}
+	return ed.dot.To
+}
+
+// moveDot moves the cursor to a. If extend is true, dot is instead
+// grown or shrunk so that it spans from its anchor to a.
+func (ed *Editor) moveDot(a address.Simple, extend bool) {
+	if !extend {
+		ed.dot = address.Selection{From: a, To: a}
+		ed.scrollIntoView(a)
+		return
+	}
+	if ed.head() == ed.dot.From {
+		ed.anchor = ed.dot.To
+	} else {
+		ed.anchor = ed.dot.From
+	}
+	if a.LessThan(ed.anchor) {
+		ed.dot = address.Selection{From: a, To: ed.anchor}
+	} else {
+		ed.dot = address.Selection{From: ed.anchor, To: a}
+	}
+	ed.scrollIntoView(a)
+}
+
+// moveLines moves the head of dot n lines down, or up if n is negative,
+// keeping to the column it was in before the last run of vertical
+// movement where possible. If extend is true, dot is extended rather
+// than collapsed.
+func (ed *Editor) moveLines(n int, extend bool) {
+	head := ed.head()
+	pt := ed.getPixelsAbs(head)
+	if ed.sticky.a == head {
+		pt.X = ed.sticky.x
+	}
+	x := pt.X
+	pt.Y += n * ed.fontHeight
+	a := ed.getAddress(pt)
+	ed.moveDot(a, extend)
+	ed.sticky.a, ed.sticky.x = a, x
+}
+
+// pageRows returns the number of rows moved by PageUp or PageDown.
+func (ed *Editor) pageRows() int {
+	n := ed.visible().Dy()/ed.fontHeight - 1
+	if n < 1 {
+		n = 1
 	}
+	return n
 }
 
 func (ed *Editor) backspace(n int) {
@@ -158,7 +133,7 @@ func (ed *Editor) backspace(n int) {
 		ed.dot.From = ed.buffer.PrevSimple(ed.dot.From)
 		n--
 	}
-	ed.dot = ed.buffer.ClearSel(ed.dot)
+	ed.dot = ed.clearSel(ed.dot)
 }
 
 func (ed *Editor) getIndentation() string {
@@ -0,0 +1,122 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestShiftSelect(t *testing.T) {
+	face := basicfont.Face7x13
+	ed := NewEditor(face, SimpleTheme)
+	ed.Load([]byte("the quick brown fox\njumps over the lazy dog"))
+	ed.SetDot(address.Selection{From: address.Simple{0, 4}, To: address.Simple{0, 4}})
+
+	cases := []struct {
+		event key.Event
+		want  string
+	}{
+		{key.Event{Code: key.CodeRightArrow, Modifiers: key.ModShift}, "q"},
+		{key.Event{Code: key.CodeRightArrow, Modifiers: key.ModShift | key.ModAlt}, "quick"},
+		{key.Event{Code: key.CodeRightArrow, Modifiers: key.ModShift | key.ModAlt}, "quick brown"},
+		{key.Event{Code: key.CodeLeftArrow, Modifiers: key.ModShift | key.ModAlt}, "quick "},
+		{key.Event{Code: key.CodeDownArrow, Modifiers: key.ModShift}, "quick brown fox\njumps over"},
+		{key.Event{Code: key.CodeEnd, Modifiers: key.ModShift}, "quick brown fox\njumps over the lazy dog"},
+		{key.Event{Code: key.CodeUpArrow, Modifiers: key.ModShift}, "quick brown fox"},
+		{key.Event{Code: key.CodeHome, Modifiers: key.ModShift}, "the "},
+		{key.Event{Code: key.CodeRightArrow, Modifiers: key.ModShift}, "he "},
+		{key.Event{Code: key.CodeRightArrow}, ""},
+	}
+	for i, c := range cases {
+		ed.SendKeyEvent(c.event)
+		if got := ed.GetDotContents(); got != c.want {
+			t.Errorf("case %d: got %q, wanted %q", i, got, c.want)
+		}
+	}
+	want := address.Simple{0, 5}
+	if ed.dot.From != want {
+		t.Errorf("got dot %v, wanted %v", ed.dot.From, want)
+	}
+}
+
+func TestCursorKeys(t *testing.T) {
+	face := basicfont.Face7x13
+	opts := *SimpleTheme
+	opts.CursorKeys = true
+	ed := NewEditor(face, &opts)
+	ed.Load([]byte("abcdefgh\nab\nabcdefgh\n"))
+	ed.SetDot(address.Selection{From: address.Simple{0, 6}, To: address.Simple{0, 6}})
+
+	down := key.Event{Code: key.CodeDownArrow}
+	up := key.Event{Code: key.CodeUpArrow}
+	cases := []struct {
+		event key.Event
+		want  address.Simple
+	}{
+		{down, address.Simple{1, 2}},
+		{down, address.Simple{2, 6}},
+		{down, address.Simple{3, 0}},
+		{up, address.Simple{2, 6}},
+		{key.Event{Code: key.CodeLeftArrow}, address.Simple{2, 5}},
+		{up, address.Simple{1, 2}},
+		{up, address.Simple{0, 5}},
+		{up, address.Simple{0, 0}},
+	}
+	for i, c := range cases {
+		ed.SendKeyEvent(c.event)
+		if !ed.dot.IsEmpty() || ed.dot.From != c.want {
+			t.Errorf("case %d: got %v, wanted %v", i, ed.dot, c.want)
+		}
+	}
+}
+
+func TestKeymap(t *testing.T) {
+	face := basicfont.Face7x13
+	opts := *SimpleTheme
+	opts.Keymap = DefaultKeymap.Clone()
+	opts.Keymap[Chord{key.ModControl, key.CodeB}] = "lineStart"
+	opts.Keymap[Chord{key.ModControl, key.CodeL}] = "upper"
+	delete(opts.Keymap, Chord{key.ModControl, key.CodeA})
+	ed := NewEditor(face, &opts)
+	ed.SetAction("upper", func(ed *Editor) {
+		ed.Replace(strings.ToUpper(ed.GetDotContents()))
+	})
+
+	for _, r := range "hello" {
+		ed.SendKeyEvent(key.Event{Rune: r})
+	}
+	ed.SendKeyEvent(key.Event{Code: key.CodeB, Modifiers: key.ModControl})
+	ed.SendKeyEvent(key.Event{Code: key.CodeEnd, Modifiers: key.ModShift})
+	ed.SendKeyEvent(key.Event{Code: key.CodeL, Modifiers: key.ModControl})
+	ed.SendKeyEvent(key.Event{Code: key.CodeA, Modifiers: key.ModControl})
+
+	if got, want := string(ed.Contents()), "HELLO"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if _, ok := DefaultKeymap[Chord{key.ModControl, key.CodeA}]; !ok {
+		t.Error("modifying a clone of DefaultKeymap modified DefaultKeymap")
+	}
+
+	ed.SendUndo()
+	if got, want := string(ed.Contents()), "hello"; got != want {
+		t.Errorf("after undo: got %q, wanted %q", got, want)
+	}
+}
+
+func TestKeymapModifiers(t *testing.T) {
+	// keys without a printable rune ignore modifiers they aren't bound with
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	for _, r := range "ab" {
+		ed.SendKeyEvent(key.Event{Rune: r})
+	}
+	ed.SendKeyEvent(key.Event{Rune: -1, Code: key.CodeDeleteBackspace, Modifiers: key.ModControl})
+	ed.SendKeyEvent(key.Event{Rune: -1, Code: key.CodeReturnEnter, Modifiers: key.ModAlt})
+	ed.SendKeyEvent(key.Event{Rune: 'c'})
+	if got, want := string(ed.Contents()), "a\nc"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
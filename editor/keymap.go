@@ -0,0 +1,437 @@
+package editor
+
+import (
+	"image"
+	"unicode/utf8"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// A Chord is a key pressed together with a set of modifier keys.
+type Chord struct {
+	Modifiers key.Modifiers
+	Code      key.Code
+}
+
+// A Keymap maps key chords to the names of editor actions. Key events
+// which aren't found in the Keymap insert their rune, if any. A key with
+// no printable rune, such as backspace or return, which isn't bound with
+// the modifiers pressed is looked up with only shift, then with no
+// modifiers.
+type Keymap map[Chord]string
+
+// Clone returns a copy of km, which may be modified without affecting km.
+func (km Keymap) Clone() Keymap {
+	clone := make(Keymap, len(km))
+	for c, name := range km {
+		clone[c] = name
+	}
+	return clone
+}
+
+// An Action is an editor operation which can be bound to a Chord by name.
+type Action func(ed *Editor)
+
+// DefaultKeymap holds the Editor's default key bindings. It is used
+// when OptionSet.Keymap is nil.
+var DefaultKeymap = Keymap{
+	{0, key.CodeEscape}:                     "escape",
+	{0, key.CodeDeleteBackspace}:            "backspace",
+	{key.ModShift, key.CodeDeleteBackspace}: "backspace",
+	{key.ModControl, key.CodeH}:             "backspace",
+	{key.ModControl, key.CodeW}:             "deleteWord",
+	{key.ModControl, key.CodeU}:             "deleteLine",
+	{0, key.CodeReturnEnter}:                "newline",
+	{key.ModShift, key.CodeReturnEnter}:     "newline",
+	{key.ModControl, key.CodeJ}:             "newline",
+
+	// kill ring
+	{key.ModControl, key.CodeK}: "killLine",
+	{key.ModControl, key.CodeY}: "yank",
+	{key.ModAlt, key.CodeY}:     "yankPop",
+	{key.ModMeta, key.CodeY}:    "yankPop",
+
+	// movement
+	{0, key.CodeUpArrow}:             "up",
+	{0, key.CodeDownArrow}:           "down",
+	{0, key.CodePageUp}:              "pageUp",
+	{0, key.CodePageDown}:            "pageDown",
+	{0, key.CodeLeftArrow}:           "left",
+	{0, key.CodeRightArrow}:          "right",
+	{key.ModAlt, key.CodeLeftArrow}:  "wordLeft",
+	{key.ModAlt, key.CodeRightArrow}: "wordRight",
+	{0, key.CodeHome}:                "lineStart",
+	{0, key.CodeEnd}:                 "lineEnd",
+	{key.ModControl, key.CodeA}:      "lineStart",
+	{key.ModControl, key.CodeE}:      "lineEnd",
+
+	// selection
+	{key.ModShift, key.CodeUpArrow}:                 "selectUp",
+	{key.ModShift, key.CodeDownArrow}:               "selectDown",
+	{key.ModShift, key.CodePageUp}:                  "selectPageUp",
+	{key.ModShift, key.CodePageDown}:                "selectPageDown",
+	{key.ModShift, key.CodeLeftArrow}:               "selectLeft",
+	{key.ModShift, key.CodeRightArrow}:              "selectRight",
+	{key.ModShift | key.ModAlt, key.CodeLeftArrow}:  "selectWordLeft",
+	{key.ModShift | key.ModAlt, key.CodeRightArrow}: "selectWordRight",
+	{key.ModShift, key.CodeHome}:                    "selectLineStart",
+	{key.ModShift, key.CodeEnd}:                     "selectLineEnd",
+	{key.ModMeta, key.CodeA}:                        "selectAll",
+
+	// multiple selections
+	{key.ModMeta, key.CodeD}:                "addNext",
+	{key.ModMeta | key.ModShift, key.CodeL}: "splitLines",
+
+	// snarf and history
+	{key.ModMeta, key.CodeC}:                "snarf",
+	{key.ModMeta, key.CodeV}:                "paste",
+	{key.ModMeta | key.ModShift, key.CodeV}: "pasteCycle",
+	{key.ModShift, key.CodeInsert}:          "pastePrimary",
+	{key.ModMeta, key.CodeX}:                "cut",
+	{key.ModMeta, key.CodeZ}:                "undo",
+	{key.ModMeta | key.ModShift, key.CodeZ}: "redo",
+}
+
+// SetAction defines an action named name for the Editor, which can
+// then be bound in a Keymap. An action defined this way replaces any
+// built-in action of the same name. Any pending input is committed to
+// the Editor's history before fn is called.
+func (ed *Editor) SetAction(name string, fn Action) {
+	if ed.actions == nil {
+		ed.actions = make(map[string]Action)
+	}
+	ed.actions[name] = fn
+}
+
+func (ed *Editor) keymap() Keymap {
+	if ed.opts.Keymap != nil {
+		return ed.opts.Keymap
+	}
+	return DefaultKeymap
+}
+
+// lookupKey returns the name of the action bound to the chord of e and
+// its function, if any, falling back to fewer modifiers for keys which
+// have no printable rune.
+func (ed *Editor) lookupKey(e key.Event) (string, Action, bool) {
+	c := Chord{e.Modifiers, e.Code}
+	if name, fn, ok := ed.lookupAction(c); ok || isGraphic(e.Rune) {
+		return name, fn, ok
+	}
+	if shift := c.Modifiers & key.ModShift; shift != c.Modifiers {
+		if name, fn, ok := ed.lookupAction(Chord{shift, c.Code}); ok {
+			return name, fn, ok
+		}
+	}
+	if c.Modifiers == 0 {
+		return "", nil, false
+	}
+	return ed.lookupAction(Chord{0, c.Code})
+}
+
+// lookupAction returns the name of the action bound to c and its
+// function, if any.
+func (ed *Editor) lookupAction(c Chord) (string, Action, bool) {
+	name, ok := ed.keymap()[c]
+	if !ok {
+		return "", nil, false
+	}
+	if fn, ok := ed.actions[name]; ok {
+		return name, func(ed *Editor) {
+			ed.commitTransformation()
+			fn(ed)
+		}, true
+	}
+	fn, ok := builtinActions[name]
+	return name, fn, ok
+}
+
+// builtinActions are called with a transformation initialized; each must
+// commit it, either before moving dot or after modifying the buffer.
+var builtinActions = map[string]Action{
+	"escape": func(ed *Editor) {
+		if len(ed.extra) > 0 {
+			// leave only dot
+			ed.commitTransformation()
+			ed.extra = nil
+			return
+		}
+		if ed.dot.IsEmpty() {
+			ed.dot.From.Col -= utf8.RuneCountInString(ed.uncommitted.Post.Text)
+		} else {
+			ed.dot = ed.clearSel(ed.dot)
+		}
+		ed.commitTransformation()
+	},
+
+	"backspace": func(ed *Editor) {
+		if len(ed.extra) > 0 {
+			ed.backspaceAll()
+		} else {
+			ed.backspace(1)
+		}
+		ed.commitTransformation()
+	},
+
+	"deleteWord": func(ed *Editor) {
+		if ed.dot.From.Col == 0 {
+			ed.killBackward(1)
+		} else {
+			line := []rune(ed.buffer.Lines[ed.dot.From.Row].String())
+			var n, dot int
+			for dot = ed.dot.From.Col; dot > 0 && !isWordChar(line[dot-1]); dot-- {
+				n++
+			}
+			for ; dot > 0 && isWordChar(line[dot-1]); dot-- {
+				n++
+			}
+			ed.killBackward(n)
+		}
+		ed.commitTransformation()
+	},
+
+	"deleteLine": func(ed *Editor) {
+		if ed.dot.From.Col == 0 {
+			ed.killBackward(1)
+		} else {
+			ed.killBackward(ed.dot.From.Col)
+		}
+		ed.commitTransformation()
+	},
+
+	"killLine": func(ed *Editor) {
+		ed.commitTransformation()
+		if ed.dot.IsEmpty() {
+			ed.dot.To.Col = ed.buffer.Lines[ed.dot.To.Row].RuneCount()
+			if ed.dot.IsEmpty() {
+				ed.dot.To = ed.buffer.NextSimple(ed.dot.To)
+			}
+		}
+		ed.kill(ed.buffer.GetSel(ed.dot), false)
+		ed.initTransformation()
+		ed.dot = ed.clearSel(ed.dot)
+		ed.commitTransformation()
+	},
+
+	"yank": func(ed *Editor) {
+		ed.commitTransformation()
+		if s, ok := ed.kills.top(); ok {
+			ed.yank(s)
+		}
+	},
+
+	"yankPop": func(ed *Editor) {
+		ed.commitTransformation()
+		if ed.lastAction != "yank" && ed.lastAction != "yankPop" {
+			return
+		}
+		if s, ok := ed.kills.rotate(); ok {
+			ed.dot = ed.yanked
+			ed.yank(s)
+		}
+	},
+
+	"newline": func(ed *Editor) {
+		if len(ed.extra) > 0 {
+			ed.newlineAll()
+			ed.commitTransformation()
+			return
+		}
+		prefix := ""
+		if ed.opts.AutoIndent {
+			prefix = ed.getIndentation()
+		}
+		ed.putString("\n" + prefix)
+		ed.dot.From = ed.dot.To
+		ed.uncommitted.Post.Text += "\n"
+		ed.commitTransformation()
+	},
+
+	"up": func(ed *Editor) {
+		ed.commitTransformation()
+		if ed.opts.CursorKeys {
+			ed.moveLines(-1, false)
+		} else {
+			ed.scroll(image.Pt(0, 18*ed.fontHeight))
+		}
+	},
+
+	"down": func(ed *Editor) {
+		ed.commitTransformation()
+		if ed.opts.CursorKeys {
+			ed.moveLines(1, false)
+		} else {
+			ed.scroll(image.Pt(0, -18*ed.fontHeight))
+		}
+	},
+
+	"selectUp": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveLines(-1, true)
+	},
+
+	"selectDown": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveLines(1, true)
+	},
+
+	"pageUp": func(ed *Editor) {
+		ed.commitTransformation()
+		n := ed.pageRows()
+		ed.scroll(image.Pt(0, n*ed.fontHeight))
+		if ed.opts.CursorKeys {
+			ed.moveLines(-n, false)
+		}
+	},
+
+	"pageDown": func(ed *Editor) {
+		ed.commitTransformation()
+		n := ed.pageRows()
+		ed.scroll(image.Pt(0, -n*ed.fontHeight))
+		if ed.opts.CursorKeys {
+			ed.moveLines(n, false)
+		}
+	},
+
+	"selectPageUp": func(ed *Editor) {
+		ed.commitTransformation()
+		n := ed.pageRows()
+		ed.scroll(image.Pt(0, n*ed.fontHeight))
+		ed.moveLines(-n, true)
+	},
+
+	"selectPageDown": func(ed *Editor) {
+		ed.commitTransformation()
+		n := ed.pageRows()
+		ed.scroll(image.Pt(0, -n*ed.fontHeight))
+		ed.moveLines(n, true)
+	},
+
+	"left": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.PrevSimple(ed.dot.From), false)
+	},
+
+	"right": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.NextSimple(ed.dot.To), false)
+	},
+
+	"selectLeft": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.PrevSimple(ed.head()), true)
+	},
+
+	"selectRight": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.NextSimple(ed.head()), true)
+	},
+
+	"wordLeft": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.PrevWord(ed.dot.From), false)
+	},
+
+	"wordRight": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.NextWord(ed.dot.To), false)
+	},
+
+	"selectWordLeft": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.PrevWord(ed.head()), true)
+	},
+
+	"selectWordRight": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(ed.buffer.NextWord(ed.head()), true)
+	},
+
+	"lineStart": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(address.Simple{Row: ed.dot.From.Row}, false)
+	},
+
+	"lineEnd": func(ed *Editor) {
+		ed.commitTransformation()
+		row := ed.dot.To.Row
+		ed.moveDot(address.Simple{Row: row, Col: ed.buffer.Lines[row].RuneCount()}, false)
+	},
+
+	"selectLineStart": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.moveDot(address.Simple{Row: ed.head().Row}, true)
+	},
+
+	"selectLineEnd": func(ed *Editor) {
+		ed.commitTransformation()
+		row := ed.head().Row
+		ed.moveDot(address.Simple{Row: row, Col: ed.buffer.Lines[row].RuneCount()}, true)
+	},
+
+	"snarf": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.snarf()
+	},
+
+	"paste": func(ed *Editor) {
+		ed.paste()
+		ed.commitTransformation()
+	},
+
+	"pasteCycle": func(ed *Editor) {
+		ed.commitTransformation()
+		if ed.lastAction != "paste" && ed.lastAction != "pasteCycle" || len(ed.extra) > 0 {
+			return
+		}
+		// replace the pasted text with the previous entry in the snarf history
+		if s, ok := ed.snarfs.rotate(); ok {
+			ed.dot = ed.pasted
+			ed.initTransformation()
+			ed.put(s)
+			ed.commitTransformation()
+		}
+	},
+
+	"pastePrimary": func(ed *Editor) {
+		ed.pastePrimary()
+		ed.commitTransformation()
+	},
+
+	"cut": func(ed *Editor) {
+		ed.snarf()
+		if len(ed.extra) > 0 {
+			ed.putAll("", false)
+		} else {
+			ed.dot = ed.clearSel(ed.dot)
+		}
+		ed.commitTransformation()
+	},
+
+	"addNext": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.addNext()
+	},
+
+	"splitLines": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.splitLines()
+	},
+
+	"selectAll": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.dot = address.Selection{To: ed.buffer.LastAddress()}
+	},
+
+	"undo": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.undo()
+	},
+
+	"redo": func(ed *Editor) {
+		ed.commitTransformation()
+		ed.redo()
+	},
+}
@@ -0,0 +1,87 @@
+package editor
+
+import "sigint.ca/graphics/editor/address"
+
+const killRingSize = 32
+
+// A ring holds recent entries, such as killed text, oldest first.
+type ring struct {
+	entries []string
+	pos     int // the entry most recently returned by top or rotate
+}
+
+// push adds s as the newest entry, discarding the oldest if the ring is full.
+func (r *ring) push(s string) {
+	if len(r.entries) == killRingSize {
+		r.entries = r.entries[1:]
+	}
+	r.entries = append(r.entries, s)
+	r.pos = len(r.entries) - 1
+}
+
+// top returns the newest entry.
+func (r *ring) top() (string, bool) {
+	if len(r.entries) == 0 {
+		return "", false
+	}
+	r.pos = len(r.entries) - 1
+	return r.entries[r.pos], true
+}
+
+// rotate returns the entry preceding the one last returned, wrapping
+// around to the newest.
+func (r *ring) rotate() (string, bool) {
+	if len(r.entries) == 0 {
+		return "", false
+	}
+	r.pos--
+	if r.pos < 0 {
+		r.pos = len(r.entries) - 1
+	}
+	return r.entries[r.pos], true
+}
+
+// killActions are the actions whose text is saved in the kill ring.
+var killActions = map[string]bool{
+	"killLine":   true,
+	"deleteWord": true,
+	"deleteLine": true,
+}
+
+// kill saves s in the kill ring. Text killed by consecutive kill actions
+// is joined into a single entry; if backward is true, s was killed from
+// before dot and is prepended to the entry.
+func (ed *Editor) kill(s string, backward bool) {
+	if s == "" {
+		return
+	}
+	n := len(ed.kills.entries)
+	if !killActions[ed.lastAction] || n == 0 {
+		ed.kills.push(s)
+	} else if backward {
+		ed.kills.entries[n-1] = s + ed.kills.entries[n-1]
+	} else {
+		ed.kills.entries[n-1] += s
+	}
+}
+
+// killBackward kills n characters before dot, as backspace(n) would
+// remove them.
+func (ed *Editor) killBackward(n int) {
+	from := ed.dot.From
+	for i := 0; i < n; i++ {
+		from = ed.buffer.PrevSimple(from)
+	}
+	ed.kill(ed.buffer.GetSel(address.Selection{From: from, To: ed.dot.To}), true)
+	ed.backspace(n)
+}
+
+// yank inserts s in place of dot, and remembers where it was inserted
+// for a following yank-pop.
+func (ed *Editor) yank(s string) {
+	ed.initTransformation()
+	ed.putString(s)
+	ed.commitTransformation()
+	ed.yanked = ed.dot
+	ed.dot.From = ed.dot.To
+}
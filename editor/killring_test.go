@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestKillRing(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("alpha beta gamma\ndelta\nepsilon"))
+
+	ctrl := func(c key.Code) key.Event { return key.Event{Code: c, Modifiers: key.ModControl} }
+	cases := []struct {
+		dot   address.Simple
+		event key.Event
+		want  string
+	}{
+		// consecutive backward kills are joined
+		{address.Simple{0, 16}, ctrl(key.CodeW), "alpha beta \ndelta\nepsilon"},
+		{address.Simple{-1, -1}, ctrl(key.CodeW), "alpha \ndelta\nepsilon"},
+
+		// consecutive forward kills are joined, including newlines
+		{address.Simple{1, 0}, ctrl(key.CodeK), "alpha \n\nepsilon"},
+		{address.Simple{-1, -1}, ctrl(key.CodeK), "alpha \nepsilon"},
+
+		{address.Simple{0, 6}, ctrl(key.CodeY), "alpha delta\n\nepsilon"},
+		{address.Simple{-1, -1}, key.Event{Code: key.CodeY, Modifiers: key.ModAlt}, "alpha beta gamma\nepsilon"},
+		{address.Simple{-1, -1}, key.Event{Code: key.CodeY, Modifiers: key.ModAlt}, "alpha delta\n\nepsilon"},
+		{address.Simple{-1, -1}, key.Event{Code: key.CodeZ, Modifiers: key.ModMeta}, "alpha beta gamma\nepsilon"},
+		{address.Simple{-1, -1}, key.Event{Code: key.CodeZ, Modifiers: key.ModMeta}, "alpha delta\n\nepsilon"},
+		{address.Simple{-1, -1}, key.Event{Code: key.CodeZ, Modifiers: key.ModMeta}, "alpha \nepsilon"},
+	}
+	for i, c := range cases {
+		if c.dot.Row >= 0 {
+			ed.SetDot(address.Selection{From: c.dot, To: c.dot})
+		}
+		ed.SendKeyEvent(c.event)
+		if got := string(ed.Contents()); got != c.want {
+			t.Errorf("case %d:\ngot:    %q\nwanted: %q", i, got, c.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"sigint.ca/graphics/editor/address"
 
+	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/mouse"
 )
 
@@ -16,6 +17,14 @@ func (ed *Editor) SendMouseEvent(e mouse.Event) {
 		ed.handleScrollEvent(e)
 		return
 	}
+	if e.Direction == mouse.DirPress {
+		ed.cancelPreedit()
+		ed.compose = composeState{} // e.g. an alt-drag
+	} else if ed.m.buttons != 0 || e.Direction == mouse.DirRelease {
+		// alt pressed while a button is held, e.g. to copy dragged
+		// text, doesn't start a sequence
+		ed.compose.armed = false
+	}
 	ed.handleMouseEvent(e)
 }
 
@@ -26,29 +35,42 @@ const (
 )
 
 const dClickPause = 500 * time.Millisecond
-const twitch = 3 // pixels
+const twitch = 3 // points
 
 type mouseState struct {
 	buttons       uint32    // a bit field of mouse buttons currently pressed
 	chording      bool      // a chord has been initiated
 	scrolling     bool      // the scroll bar is being manipulated
+	lines         bool      // whole lines are being swept from the gutter
+	block         bool      // a rectangular block is being swept
+	drag          bool      // dot is being dragged
+	dragged       bool      // the pointer has moved since the drag started
 	lastClickTime time.Time // used to detect a double-click
 
-	pt image.Point
-	a  address.Simple
+	pos image.Point // the last position of the pointer, relative to the Editor
+	pt  image.Point
+	a   address.Simple
 
 	sweepOrigin image.Point    // the origin of a sweep
 	sweepLast   address.Simple // the last column that was swept
+	drop        address.Simple // where dragged text will be dropped
+
+	argChord bool              // B1 was pressed while B2 was held
+	arg      address.Selection // dot before B2 was pressed, for a B2-B1 chord
 }
 
 func (ed *Editor) handleScrollEvent(e mouse.Event) {
+	// shift turns a vertical wheel into a horizontal one
+	if e.Modifiers&key.ModShift != 0 && e.ScrollDelta.X == 0 {
+		e.ScrollDelta.X, e.ScrollDelta.Y = e.ScrollDelta.Y, 0
+	}
 	if !e.PreciseScrolling {
 		e.ScrollDelta.X *= ed.fontHeight
 		e.ScrollDelta.Y *= ed.fontHeight
 	}
-	oldPt := ed.scrollPt
+	oldPt := ed.scrollPt()
 	ed.scroll(e.ScrollDelta)
-	if ed.scrollPt != oldPt {
+	if ed.scrollPt() != oldPt {
 		ed.dirty = true
 	}
 }
@@ -58,9 +80,12 @@ func (ed *Editor) handleMouseEvent(e mouse.Event) {
 		return
 	}
 
-	// a mouse event commits any pending transformation
+	// a mouse event commits any pending transformation, and ends any
+	// run of kills or yanks
 	ed.commitTransformation()
+	ed.lastAction = ""
 
+	ed.m.pos = e.Pos
 	ed.m.pt = e.Pos.Add(ed.visible().Min) // adjust for scrolling
 	ed.m.a = ed.getAddress(ed.m.pt)
 
@@ -68,6 +93,8 @@ func (ed *Editor) handleMouseEvent(e mouse.Event) {
 		ed.release(e)
 	} else if e.Direction == mouse.DirPress && e.Pos.In(ed.sbRect()) || ed.m.scrolling {
 		ed.clickSb(e)
+	} else if e.Direction == mouse.DirPress && e.Button == mouse.ButtonLeft && e.Pos.In(ed.gutterRect()) {
+		ed.clickGutter(e)
 	} else if e.Direction == mouse.DirPress {
 		ed.click(e)
 	} else if e.Direction == mouse.DirNone {
@@ -98,13 +125,22 @@ func (ed *Editor) clickSb(e mouse.Event) {
 		ed.scroll(image.Pt(0, d))
 
 	case mouse.ButtonMiddle:
-		ed.scrollPt.Y = int(float64(ed.docHeight()) * percent)
-		ed.scroll(image.ZP) // fix potential invalid scrollPt
+		ed.setScrollPt(image.Pt(ed.scrollPt().X, int(float64(ed.docHeight())*percent)))
+		ed.scroll(image.ZP) // fix potential invalid scroll position
 
 	}
 	ed.dirty = true
 }
 
+// gutter click; selects whole lines
+func (ed *Editor) clickGutter(e mouse.Event) {
+	ed.m.buttons |= 1 << uint(e.Button)
+	ed.m.sweepOrigin = ed.m.pt
+	ed.m.lines = true
+	ed.dot = ed.buffer.SelLine(ed.m.a)
+	ed.dirty = true
+}
+
 func (ed *Editor) click(e mouse.Event) {
 	a, pt := ed.m.a, ed.m.pt
 
@@ -114,6 +150,21 @@ func (ed *Editor) click(e mouse.Event) {
 	dprintf("click: ed.m.buttons: %v\n", ed.m.buttons)
 	switch ed.m.buttons {
 	case b1:
+		if e.Modifiers&key.ModMeta != 0 {
+			// add a cursor
+			ed.AddSelection(address.Selection{From: a, To: a})
+			break
+		}
+		ed.extra = nil
+		if e.Modifiers&key.ModAlt != 0 {
+			// start a block selection
+			ed.m.block = true
+			ed.dot = address.Selection{From: a, To: a}
+			break
+		}
+		if ed.startDrag(a) {
+			break
+		}
 		prev := ed.dot
 		ed.dot.From, ed.dot.To = a, a
 
@@ -126,6 +177,7 @@ func (ed *Editor) click(e mouse.Event) {
 		}
 
 	case b2:
+		ed.m.arg = ed.dot
 		if ed.dot.IsEmpty() || !a.In(ed.dot) {
 			ed.dot = ed.buffer.SelFunc(a, unicode.IsSpace)
 		}
@@ -136,11 +188,21 @@ func (ed *Editor) click(e mouse.Event) {
 		}
 
 	case b1 | b2:
-		// cut
 		ed.m.chording = true
+		if e.Button == mouse.ButtonLeft {
+			// execute with an argument, when the buttons are released
+			ed.m.argChord = true
+			break
+		}
+
+		// cut
 		ed.initTransformation()
 		ed.snarf()
-		ed.dot = ed.buffer.ClearSel(ed.dot)
+		if len(ed.extra) > 0 {
+			ed.putAll("", false)
+		} else {
+			ed.dot = ed.clearSel(ed.dot)
+		}
 		ed.commitTransformation()
 
 	case b1 | b3:
@@ -158,6 +220,10 @@ func (ed *Editor) click(e mouse.Event) {
 func (ed *Editor) sweep(e mouse.Event) {
 	a, pt := ed.m.a, ed.m.pt
 
+	if ed.m.drag && !ed.m.chording {
+		ed.dragTo(e)
+		return
+	}
 	vis := ed.visible()
 	if a == ed.m.sweepLast && pt.In(vis) {
 		return
@@ -165,22 +231,27 @@ func (ed *Editor) sweep(e mouse.Event) {
 	if ed.m.chording == true {
 		return
 	}
-	if isTwitch(pt, ed.m.sweepOrigin) {
+	if isTwitch(pt, ed.m.sweepOrigin, ed.px(twitch)) {
 		return
 	}
 
-	oldScrollPt := ed.scrollPt
-	if pt.Y <= vis.Min.Y && vis.Min.Y > 0 {
-		ed.scroll(image.Pt(0, ed.fontHeight))
-	} else if pt.Y >= vis.Max.Y && vis.Max.Y < ed.docHeight() {
-		ed.scroll(image.Pt(0, -ed.fontHeight))
-	}
+	oldScrollPt := ed.scrollPt()
+	ed.edgeScroll(e.Pos)
 
 	ed.m.sweepLast = a
 
 	oldDot := ed.dot
 	origin := ed.getAddress(ed.m.sweepOrigin)
-	if a.LessThan(origin) {
+	if ed.m.block {
+		ed.selectBlock(ed.m.sweepOrigin, pt)
+		ed.dirty = true // the other rows may have changed
+	} else if ed.m.lines {
+		from, to := ed.buffer.SelLine(origin), ed.buffer.SelLine(a)
+		if a.Row < origin.Row {
+			from, to = to, from
+		}
+		ed.dot = address.Selection{From: from.From, To: to.To}
+	} else if a.LessThan(origin) {
 		ed.dot = address.Selection{a, origin}
 	} else if a != origin {
 		ed.dot = address.Selection{origin, a}
@@ -188,14 +259,60 @@ func (ed *Editor) sweep(e mouse.Event) {
 		ed.dot = address.Selection{origin, origin}
 	}
 
-	if ed.dot != oldDot || ed.scrollPt != oldScrollPt {
+	if ed.dot != oldDot || ed.scrollPt() != oldScrollPt {
 		ed.dirty = true
 	}
 }
 
-// isTwitch reports whether p1 is within 1 twitch distance of p2.
-func isTwitch(p1, p2 image.Point) bool {
-	size := image.Pt(twitch, twitch)
+// edgeScroll scrolls the text if pos, relative to the Editor, is
+// at or beyond one of its edges.
+func (ed *Editor) edgeScroll(pos image.Point) {
+	if d := ed.edgeScrollDelta(pos); d != (image.Point{}) {
+		ed.scroll(d)
+	}
+}
+
+// selectBlock selects the columns between the pixel offsets of p1 and
+// p2 on each row between them, with dot on the row of p2.
+func (ed *Editor) selectBlock(p1, p2 image.Point) {
+	r1, r2 := ed.getAddress(p1).Row, ed.getAddress(p2).Row
+	x1, x2 := p1.X, p2.X
+	if x2 < x1 {
+		x1, x2 = x2, x1
+	}
+	ed.extra = ed.extra[:0]
+	for row := r1; ; {
+		y := ed.getPixelsAbs(address.Simple{Row: row}).Y
+		sel := address.Selection{From: ed.getAddress(image.Pt(x1, y)), To: ed.getAddress(image.Pt(x2, y))}
+		if row == r2 {
+			ed.dot = sel
+			break
+		}
+		ed.extra = append(ed.extra, sel)
+		if r1 < r2 {
+			row++
+		} else {
+			row--
+		}
+	}
+}
+
+// executeArg performs the action of a B2-B1 chord. Dot is restored to
+// the argument, so that B2ArgAction can act on it.
+func (ed *Editor) executeArg() {
+	cmd := ed.buffer.GetSel(ed.dot)
+	if ed.B2ArgAction != nil {
+		ed.dot = ed.m.arg
+		ed.B2ArgAction(cmd, ed.buffer.GetSel(ed.m.arg))
+	} else if ed.B2Action != nil {
+		ed.B2Action(cmd)
+	}
+	ed.dirty = true
+}
+
+// isTwitch reports whether p1 is within d pixels of p2.
+func isTwitch(p1, p2 image.Point, d int) bool {
+	size := image.Pt(d, d)
 	r := image.Rectangle{p2.Sub(size), p2.Add(size)}
 	return p1.In(r)
 }
@@ -204,6 +321,12 @@ func (ed *Editor) release(e mouse.Event) {
 	ed.m.scrolling = false
 
 	switch ed.m.buttons {
+	case b1:
+		if !ed.m.chording && ed.m.drag {
+			ed.drop(e)
+		} else if !ed.m.chording {
+			ed.setPrimary()
+		}
 	case b2:
 		if !ed.m.chording && ed.B2Action != nil {
 			ed.B2Action(ed.buffer.GetSel(ed.dot))
@@ -216,10 +339,22 @@ func (ed *Editor) release(e mouse.Event) {
 		}
 	}
 
+	if len(ed.extra) > 0 {
+		// dot may have been swept over other selections
+		ed.mergeSelections()
+	}
+
 	ed.m.buttons &^= 1 << uint(e.Button)
+	if ed.m.buttons&(b1|b2|b3) == 0 && ed.m.argChord {
+		ed.m.argChord = false
+		ed.executeArg()
+	}
 	if ed.m.buttons&(b1|b2|b3) == 0 {
 		dprintf("release: ed.mchording=false (was %v)\n", ed.m.chording)
 		ed.m.chording = false
+		ed.m.lines = false
+		ed.m.block = false
+		ed.m.drag = false
 	}
 	dprintf("release: ed.m.buttons = %v\n", ed.m.buttons)
 }
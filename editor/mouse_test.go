@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestGutter(t *testing.T) {
+	face := basicfont.Face7x13
+	opts := *SimpleTheme
+	opts.LineNumbers = AbsoluteLineNumbers
+	ed := NewEditor(face, &opts)
+	ed.Load([]byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 200, 200)), image.Rect(0, 0, 200, 200))
+
+	// 11 lines need two digits of gutter
+	if got, want := ed.textLeft(), 2*7+2*ed.margin; got != want {
+		t.Errorf("got text offset %d, wanted %d", got, want)
+	}
+	a := address.Simple{Row: 2, Col: 3}
+	if got := ed.getAddress(ed.getPixelsAbs(a)); got != a {
+		t.Errorf("getAddress(getPixelsAbs(%v)) = %v", a, got)
+	}
+
+	x := ed.gutterRect().Min.X + 1
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, 13+6), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	if got, want := ed.GetDotContents(), "two\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, 3*13+6), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	if got, want := ed.GetDotContents(), "two\nthree\nfour\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, 6), Button: mouse.ButtonLeft, Direction: mouse.DirNone})
+	if got, want := ed.GetDotContents(), "one\ntwo\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	ed.SendMouseEvent(mouse.Event{Pos: image.Pt(x, 6), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+}
+
+func TestHorizontalScroll(t *testing.T) {
+	face := basicfont.Face7x13
+	ed := NewEditor(face, SimpleTheme)
+	ed.Load([]byte("short\n" + strings.Repeat("x", 100) + "end\n"))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 200, 200)), image.Rect(0, 0, 200, 200))
+
+	ed.SendMouseEvent(mouse.Event{Button: mouse.ButtonScroll, ScrollDelta: image.Pt(0, -2), Modifiers: key.ModShift})
+	if got, want := ed.scrollPt().X, 2*13; got != want {
+		t.Errorf("after shift+wheel: got scroll offset %d, wanted %d", got, want)
+	}
+	ed.SendMouseEvent(mouse.Event{Button: mouse.ButtonScroll, ScrollDelta: image.Pt(-1000, 0), PreciseScrolling: true})
+	if got, want := ed.scrollPt().X, 103*7-ed.textWidth(); got != want {
+		t.Errorf("after scrolling past the end: got scroll offset %d, wanted %d", got, want)
+	}
+
+	ed.SetDot(address.Selection{})
+	ed.autoscroll()
+	if ed.scrollPt().X != 0 {
+		t.Errorf("got scroll offset %d, wanted 0", ed.scrollPt().X)
+	}
+	if _, ok := ed.FindNext("end"); !ok {
+		t.Fatal("FindNext failed")
+	}
+	pt := ed.getPixelsRel(ed.dot.To)
+	if pt.X < ed.textLeft() || pt.X > ed.r.Dx() {
+		t.Errorf("after FindNext: cursor at x=%d, outside of the text area", pt.X)
+	}
+}
+
+func TestB2ArgChord(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("|sort -r"))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 200, 50)), image.Rect(0, 0, 200, 50))
+	ed.SetDot(address.Selection{From: address.Simple{0, 6}, To: address.Simple{0, 8}})
+
+	var cmd, arg string
+	ed.B2ArgAction = func(c, a string) { cmd, arg = c, a }
+	ed.B2Action = func(c string) { t.Errorf("B2Action(%q) called for a B2-B1 chord", c) }
+
+	pt := image.Pt(ed.getPixelsAbs(address.Simple{0, 2}).X+1, 1)
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonMiddle, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	if cmd != "" {
+		t.Error("B2ArgAction called before all buttons were released")
+	}
+	ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonMiddle, Direction: mouse.DirRelease})
+	if cmd != "|sort" || arg != "-r" {
+		t.Errorf("got B2ArgAction(%q, %q), wanted (%q, %q)", cmd, arg, "|sort", "-r")
+	}
+	if got := string(ed.Contents()); got != "|sort -r" {
+		t.Errorf("got contents %q after chord, wanted them unchanged", got)
+	}
+	if got := ed.GetDotContents(); got != "-r" {
+		t.Errorf("got dot %q after chord, wanted the argument %q", got, "-r")
+	}
+}
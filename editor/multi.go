@@ -0,0 +1,238 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"sigint.ca/graphics/editor/address"
+	"sigint.ca/graphics/editor/internal/hist"
+)
+
+// Selections returns dot and any additional selections, in buffer order.
+func (ed *Editor) Selections() []address.Selection {
+	sels := append([]address.Selection{ed.dot}, ed.extra...)
+	sort.Slice(sels, func(i, j int) bool {
+		return sels[i].From.LessThan(sels[j].From)
+	})
+	return sels
+}
+
+// AddSelection adds sel to the Editor's selections, making it dot.
+// The previous dot is kept as an additional selection. Selections
+// which overlap sel are merged with it.
+func (ed *Editor) AddSelection(sel address.Selection) {
+	ed.cancelPreedit()
+	ed.commitTransformation()
+	ed.extra = append(ed.extra, ed.dot)
+	ed.dot = sel
+	ed.mergeSelections()
+	ed.dirty = true
+}
+
+// mergeSelections merges any selections which overlap, or touch
+// an empty selection.
+func (ed *Editor) mergeSelections() {
+	var merged []address.Selection
+	var dot int
+	for _, sel := range ed.Selections() {
+		if n := len(merged); n > 0 && overlaps(merged[n-1], sel) {
+			merged[n-1] = union(merged[n-1], sel)
+		} else {
+			merged = append(merged, sel)
+		}
+		if sel == ed.dot {
+			dot = len(merged) - 1
+		}
+	}
+	ed.dot = merged[dot]
+	ed.extra = append(merged[:dot:dot], merged[dot+1:]...)
+}
+
+// overlaps reports whether s1 and s2 share any text or, if either
+// is empty, an address.
+func overlaps(s1, s2 address.Selection) bool {
+	return !s1.To.LessThan(s2.From) && !s2.To.LessThan(s1.From) &&
+		(s1.IsEmpty() || s2.IsEmpty() || s1.From != s2.To && s2.From != s1.To)
+}
+
+func union(s1, s2 address.Selection) address.Selection {
+	if s2.From.LessThan(s1.From) {
+		s1.From = s2.From
+	}
+	if s1.To.LessThan(s2.To) {
+		s1.To = s2.To
+	}
+	return s1
+}
+
+// addNext adds a selection for the next occurrence of the text in
+// the last selection. If dot is empty, the word containing it is
+// selected instead.
+func (ed *Editor) addNext() {
+	if ed.dot.IsEmpty() {
+		ed.dot = ed.buffer.SelWord(ed.dot.From)
+		return
+	}
+	sels := ed.Selections()
+	last := sels[len(sels)-1]
+	sel, ok := ed.buffer.Find(last.To, ed.buffer.GetSel(ed.dot))
+	if !ok {
+		return
+	}
+	for _, s := range sels {
+		if s == sel {
+			// every occurrence is already selected
+			return
+		}
+	}
+	ed.AddSelection(sel)
+	ed.autoscroll()
+}
+
+// splitLines splits dot into a selection for each line it spans,
+// not including newlines.
+func (ed *Editor) splitLines() {
+	if ed.dot.From.Row == ed.dot.To.Row {
+		return
+	}
+	from, to := ed.dot.From, ed.dot.To
+	if to.Col == 0 {
+		// don't add an empty selection after a trailing newline
+		to = ed.buffer.PrevSimple(to)
+	}
+	ed.dot = address.Selection{From: from, To: address.Simple{Row: from.Row, Col: ed.buffer.Lines[from.Row].RuneCount()}}
+	for row := from.Row + 1; row <= to.Row; row++ {
+		sel := address.Selection{From: address.Simple{Row: row}, To: to}
+		if row < to.Row {
+			sel.To = address.Simple{Row: row, Col: ed.buffer.Lines[row].RuneCount()}
+		}
+		ed.extra = append(ed.extra, sel)
+	}
+	ed.mergeSelections()
+}
+
+// editAll replaces text at each of the Editor's selections. For each
+// selection, fn returns the region to be replaced and the text to
+// replace it with. Afterwards, each selection is an empty selection
+// following the inserted text, or if selectText is true, the inserted text.
+//
+// The edits are recorded in history as a single transformation of the
+// text spanning all of the selections. Consecutive calls to editAll
+// extend the same transformation, as typing does.
+func (ed *Editor) editAll(fn func(sel address.Selection) (address.Selection, string), selectText bool) {
+	type edit struct {
+		r address.Selection
+		s string
+	}
+	sels := ed.Selections()
+	edits := make([]edit, len(sels))
+	var dot int
+	for i := range sels {
+		if sels[i] == ed.dot {
+			dot = i
+		}
+		edits[i].r, edits[i].s = fn(sels[i])
+		if i > 0 && edits[i].r.From.LessThan(edits[i-1].r.To) {
+			// e.g. a backspace into the previous selection
+			edits[i].r.From = edits[i-1].r.To
+		}
+	}
+	ed.initMulti(address.Selection{From: edits[0].r.From, To: edits[len(edits)-1].r.To})
+
+	for i, e := range edits {
+		ed.clearSel(e.r)
+		end := ed.insertString(e.r.From, e.s)
+		for j := i + 1; j < len(edits); j++ {
+			edits[j].r.From = shiftAddr(edits[j].r.From, e.r.To, end)
+			edits[j].r.To = shiftAddr(edits[j].r.To, e.r.To, end)
+		}
+		ed.multi.end = shiftAddr(ed.multi.end, e.r.To, end)
+
+		sels[i] = address.Selection{From: end, To: end}
+		if selectText {
+			sels[i].From = e.r.From
+		}
+	}
+
+	ed.dot = sels[dot]
+	ed.extra = append(sels[:dot:dot], sels[dot+1:]...)
+	ed.mergeSelections()
+	ed.dirty = true
+}
+
+// initMulti starts a new transformation of the text in cover, unless
+// cover is within the text changed by the current one.
+func (ed *Editor) initMulti(cover address.Selection) {
+	if ed.multi.active && ed.uncommitted != nil &&
+		!cover.From.LessThan(ed.uncommitted.Pre.Sel.From) && !ed.multi.end.LessThan(cover.To) {
+		return
+	}
+	ed.commitTransformation()
+	ed.uncommitted = &hist.Transformation{
+		Pre: hist.Chunk{
+			Sel:  cover,
+			Text: ed.buffer.GetSel(cover),
+		},
+	}
+	ed.multi.active = true
+	ed.multi.end = cover.To
+}
+
+// shiftAddr returns the new location of a, which follows old, after the
+// text ending at old has been replaced by text ending at new.
+func shiftAddr(a, old, new address.Simple) address.Simple {
+	if a.LessThan(old) {
+		return a
+	}
+	if a.Row == old.Row {
+		return address.Simple{Row: new.Row, Col: new.Col + a.Col - old.Col}
+	}
+	return address.Simple{Row: a.Row + new.Row - old.Row, Col: a.Col}
+}
+
+// typeAll inserts s at each selection.
+func (ed *Editor) typeAll(s string) {
+	ed.editAll(func(sel address.Selection) (address.Selection, string) {
+		return sel, s
+	}, false)
+}
+
+// newlineAll inserts a newline at each selection, indented to match
+// the selection's line if AutoIndent is set.
+func (ed *Editor) newlineAll() {
+	ed.editAll(func(sel address.Selection) (address.Selection, string) {
+		prefix := ""
+		if ed.opts.AutoIndent {
+			line := ed.buffer.Lines[sel.From.Row].String()
+			prefix = line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+		}
+		return sel, "\n" + prefix
+	}, false)
+}
+
+// backspaceAll deletes each non-empty selection, or the rune before
+// each empty one.
+func (ed *Editor) backspaceAll() {
+	ed.editAll(func(sel address.Selection) (address.Selection, string) {
+		if sel.IsEmpty() {
+			sel.From = ed.buffer.PrevSimple(sel.From)
+		}
+		return sel, ""
+	}, false)
+}
+
+// putAll replaces each selection with s. If s has one line for each
+// selection, each line goes to its own selection instead.
+func (ed *Editor) putAll(s string, selectText bool) {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	split := len(ed.extra) > 0 && len(lines) == len(ed.extra)+1
+	var i int
+	ed.editAll(func(r address.Selection) (address.Selection, string) {
+		i++
+		if split {
+			return r, lines[i-1]
+		}
+		return r, s
+	}, selectText)
+}
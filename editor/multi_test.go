@@ -0,0 +1,156 @@
+package editor
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestAddNext(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("foo bar\nfoo baz\nfoo"))
+	ed.SetDot(address.Selection{From: address.Simple{0, 1}, To: address.Simple{0, 1}})
+
+	addNext := key.Event{Code: key.CodeD, Modifiers: key.ModMeta}
+	for i := 0; i < 4; i++ {
+		ed.SendKeyEvent(addNext)
+	}
+	if got := len(ed.Selections()); got != 3 {
+		t.Fatalf("got %d selections, wanted 3", got)
+	}
+
+	for _, r := range "xy" {
+		ed.SendKeyEvent(key.Event{Rune: r})
+	}
+	ed.SendKeyEvent(key.Event{Code: key.CodeDeleteBackspace})
+	ed.SendKeyEvent(key.Event{Code: key.CodeReturnEnter})
+	if got, want := string(ed.Contents()), "x\n bar\nx\n baz\nx\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	// as with a single cursor, backspace is part of the typing which
+	// preceded it, while newline is an undo step of its own
+	for _, want := range []string{"x bar\nx baz\nx", "foo bar\nfoo baz\nfoo"} {
+		ed.SendUndo()
+		if got := string(ed.Contents()); got != want {
+			t.Errorf("after undo: got %q, wanted %q", got, want)
+		}
+	}
+	ed.SendRedo()
+	if got, want := string(ed.Contents()), "x bar\nx baz\nx"; got != want {
+		t.Errorf("after redo: got %q, wanted %q", got, want)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("one\ntwo\nthree\n"))
+	ed.SetDot(address.Selection{From: address.Simple{0, 1}, To: address.Simple{3, 0}})
+
+	ed.SendKeyEvent(key.Event{Code: key.CodeL, Modifiers: key.ModMeta | key.ModShift})
+	want := []address.Selection{
+		{address.Simple{0, 1}, address.Simple{0, 3}},
+		{address.Simple{1, 0}, address.Simple{1, 3}},
+		{address.Simple{2, 0}, address.Simple{2, 5}},
+	}
+	sels := ed.Selections()
+	if len(sels) != len(want) {
+		t.Fatalf("got selections %v, wanted %v", sels, want)
+	}
+	for i := range want {
+		if sels[i] != want[i] {
+			t.Errorf("selection %d: got %v, wanted %v", i, sels[i], want[i])
+		}
+	}
+
+	ed.Replace("<>")
+	if got, want := string(ed.Contents()), "o<>\n<>\n<>\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	for _, sel := range ed.Selections() {
+		if got := ed.buffer.GetSel(sel); got != "<>" {
+			t.Errorf("got selection %q, wanted %q", got, "<>")
+		}
+	}
+}
+
+func TestAddCursor(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("abc\nabc\nabc\n"))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 100, 100)), image.Rect(0, 0, 100, 100))
+
+	click := func(a address.Simple, mod key.Modifiers) {
+		pt := ed.getPixelsRel(a)
+		ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirPress, Modifiers: mod})
+		ed.SendMouseEvent(mouse.Event{Pos: pt, Button: mouse.ButtonLeft, Direction: mouse.DirRelease, Modifiers: mod})
+	}
+	click(address.Simple{0, 1}, 0)
+	click(address.Simple{1, 2}, key.ModMeta)
+	click(address.Simple{2, 3}, key.ModMeta)
+	click(address.Simple{2, 3}, key.ModMeta)
+	ed.SendKeyEvent(key.Event{Rune: '_'})
+	if got, want := string(ed.Contents()), "a_bc\nab_c\nabc_\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	// escape leaves only dot
+	ed.SendKeyEvent(key.Event{Code: key.CodeEscape})
+	if got := len(ed.Selections()); got != 1 {
+		t.Errorf("got %d selections after escape, wanted 1", got)
+	}
+	if got, want := string(ed.Contents()), "a_bc\nab_c\nabc_\n"; got != want {
+		t.Errorf("after escape: got %q, wanted %q", got, want)
+	}
+	ed.SendKeyEvent(key.Event{Rune: '-'})
+	if got, want := string(ed.Contents()), "a_bc\nab_c\nabc_-\n"; got != want {
+		t.Errorf("after escape and typing: got %q, wanted %q", got, want)
+	}
+
+	click(address.Simple{0, 0}, 0)
+	if got := len(ed.Selections()); got != 1 {
+		t.Errorf("got %d selections after a plain click, wanted 1", got)
+	}
+}
+
+func TestBlockSelect(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("a\tbcd\nabcdefgh\nab\n"))
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, 100, 100)), image.Rect(0, 0, 100, 100))
+
+	// sweep the pixel columns of "ef" from the last row to the first
+	p1 := ed.getPixelsRel(address.Simple{2, 4})
+	p1.X = ed.getPixelsRel(address.Simple{1, 4}).X
+	p2 := ed.getPixelsRel(address.Simple{0, 0})
+	p2.X = ed.getPixelsRel(address.Simple{1, 6}).X
+	ed.SendMouseEvent(mouse.Event{Pos: p1, Button: mouse.ButtonLeft, Direction: mouse.DirPress, Modifiers: key.ModAlt})
+	ed.SendMouseEvent(mouse.Event{Pos: p2, Button: mouse.ButtonLeft, Direction: mouse.DirNone, Modifiers: key.ModAlt})
+	ed.SendMouseEvent(mouse.Event{Pos: p2, Button: mouse.ButtonLeft, Direction: mouse.DirRelease, Modifiers: key.ModAlt})
+
+	var got []string
+	for _, sel := range ed.Selections() {
+		got = append(got, ed.buffer.GetSel(sel))
+	}
+	// the tab in the first row spans columns 1 to 4
+	want := []string{"bc", "ef", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if got, want := ed.GetDotContents(), "bc"; got != want {
+		t.Errorf("got dot %q, wanted %q", got, want)
+	}
+
+	ed.SendKeyEvent(key.Event{Rune: '|'})
+	if got, want := string(ed.Contents()), "a\t|d\nabcd|gh\nab|\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	ed.putAll("1\n2\n3\n", false)
+	if got, want := string(ed.Contents()), "a\t|1d\nabcd|2gh\nab|3\n"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
@@ -9,6 +9,15 @@ import (
 // SetOpts reconfigures the Editor according to opts.
 func (ed *Editor) SetOpts(opts *OptionSet) {
 	ed.opts = opts
+	if opts.Vi && !ed.vi.enabled {
+		// enter normal mode
+		ed.vi = viState{}
+		ed.viClamp()
+	}
+	ed.vi.enabled = opts.Vi
+	ed.setTabWidth()
+	ed.wrap = wrapCache{}
+	ed.damage.all = true
 	ed.dirty = true
 }
 
@@ -20,8 +29,59 @@ type OptionSet struct {
 	Cursor     func(height int) image.Image
 	AutoIndent bool
 	ScrollBar  bool
+
+	// Keymap defines the Editor's key bindings. If nil, DefaultKeymap
+	// is used.
+	Keymap Keymap
+
+	// Compose defines the Editor's compose sequences. If nil,
+	// DefaultComposeTable is used.
+	Compose ComposeTable
+
+	// CursorKeys causes the up and down arrow keys to move the cursor
+	// by lines, rather than scrolling the text as acme does.
+	CursorKeys bool
+
+	// Vi enables vi-style modal editing. The Editor starts in normal mode.
+	Vi bool
+
+	// LineNumbers selects how line numbers are drawn in the gutter
+	// to the left of the text.
+	LineNumbers LineNumbers
+
+	// Wrap causes lines which are too long to fit in the Editor to be
+	// wrapped, at word boundaries where possible, rather than scrolled
+	// horizontally.
+	Wrap bool
+
+	// TabWidth is the distance between tabstops, in multiples of the
+	// width of the digit 0. If it is zero, DefaultTabWidth is used.
+	TabWidth int
+
+	// ElasticTabstops causes the tab-separated cells of consecutive lines
+	// to be aligned in columns as wide as their widest cell, rather than
+	// at fixed tabstops. It has no effect when Wrap is set.
+	ElasticTabstops bool
 }
 
+// DefaultTabWidth is the tab width used when OptionSet.TabWidth is zero.
+const DefaultTabWidth = 4
+
+// LineNumbers is a line number gutter setting.
+type LineNumbers int
+
+const (
+	// NoLineNumbers disables the line number gutter.
+	NoLineNumbers LineNumbers = iota
+
+	// AbsoluteLineNumbers numbers each line from the start of the buffer.
+	AbsoluteLineNumbers
+
+	// RelativeLineNumbers numbers each line by its distance from the
+	// line containing the cursor, which is numbered absolutely.
+	RelativeLineNumbers
+)
+
 func acmeCursor(bg image.Image) func(height int) image.Image {
 	fn := func(height int) image.Image {
 		// the squares at the top and bottom of the cursor should
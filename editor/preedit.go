@@ -0,0 +1,126 @@
+package editor
+
+import (
+	"image"
+	"image/draw"
+
+	"sigint.ca/graphics/editor/address"
+)
+
+// preedit is the composition text of an input method, which is kept in
+// the buffer following dot while it is being composed, but is not
+// recorded in history. Any other selections following dot are moved
+// past it.
+type preedit struct {
+	active bool
+	sel    address.Selection // the composition text in the buffer
+	cursor address.Simple    // the input method's cursor, within sel
+}
+
+// SetPreedit sets the composition text of an input method, which is
+// drawn underlined at dot until it is replaced by another call to
+// SetPreedit, or ended by CommitPreedit. cursor is the offset in runes
+// of the input method's cursor within s. The composition text is not part
+// of the Editor's contents or history, and it is discarded by any other
+// input or change to the Editor. An empty s ends the composition.
+func (ed *Editor) SetPreedit(s string, cursor int) {
+	ed.cancelPreedit()
+	if s == "" {
+		return
+	}
+	from := ed.dot.To
+	to := ed.insertString(from, s)
+	ed.preedit = preedit{
+		active: true,
+		sel:    address.Selection{From: from, To: to},
+		cursor: from,
+	}
+	for i, sel := range ed.extra {
+		ed.extra[i].From = shiftAddr(sel.From, from, to)
+		ed.extra[i].To = shiftAddr(sel.To, from, to)
+	}
+	for i := 0; i < cursor && ed.preedit.cursor.LessThan(to); i++ {
+		ed.preedit.cursor = ed.buffer.NextSimple(ed.preedit.cursor)
+	}
+	ed.scrollIntoView(ed.preedit.cursor)
+	ed.dirty = true
+}
+
+// CommitPreedit ends the composition of an input method, and inserts s
+// at dot as if it had been typed.
+func (ed *Editor) CommitPreedit(s string) {
+	ed.cancelPreedit()
+	if s == "" {
+		return
+	}
+	ed.initTransformation()
+	ed.typeString(s)
+	ed.dirty = true
+}
+
+// cancelPreedit removes any composition text from the buffer.
+func (ed *Editor) cancelPreedit() {
+	if !ed.preedit.active {
+		return
+	}
+	sel := ed.preedit.sel
+	ed.clearSel(sel)
+	for i, x := range ed.extra {
+		ed.extra[i].From = shiftAddr(x.From, sel.To, sel.From)
+		ed.extra[i].To = shiftAddr(x.To, sel.To, sel.From)
+	}
+	ed.preedit = preedit{}
+	ed.dirty = true
+}
+
+// contents returns the contents of the buffer, without any composition text.
+func (ed *Editor) contents() []byte {
+	if !ed.preedit.active {
+		return ed.buffer.Contents()
+	}
+	last := ed.buffer.LastAddress()
+	before := ed.buffer.GetSel(address.Selection{To: ed.preedit.sel.From})
+	after := ed.buffer.GetSel(address.Selection{From: ed.preedit.sel.To, To: last})
+	return []byte(before + after)
+}
+
+// preeditSels returns sels, with the cursor at dot replaced by the input
+// method's cursor if there is any composition text.
+func (ed *Editor) preeditSels(sels []address.Selection) []address.Selection {
+	if !ed.preedit.active {
+		return sels
+	}
+	c := address.Selection{From: ed.preedit.cursor, To: ed.preedit.cursor}
+	for i, sel := range sels {
+		if sel == ed.dot && sel.IsEmpty() {
+			sels[i] = c
+			return sels
+		}
+	}
+	return append(sels, c)
+}
+
+// drawPreeditLine underlines the part of the composition text on the
+// display line seg of row, which is drawn at pt with layout lay.
+func (ed *Editor) drawPreeditLine(dst *image.RGBA, row int, seg segment, pt image.Point, lay layout) {
+	sel := ed.preedit.sel
+	if !ed.preedit.active || row < sel.From.Row || row > sel.To.Row {
+		return
+	}
+	from, to := seg.start, seg.end
+	if row == sel.From.Row && sel.From.Col > from {
+		from = sel.From.Col
+	}
+	if row == sel.To.Row && sel.To.Col < to {
+		to = sel.To.Col
+	}
+	thickness := ed.px(1)
+	if thickness < 1 {
+		thickness = 1
+	}
+	y := pt.Y + ed.fontHeight - thickness
+	for _, x := range lay.ranges(from-seg.start, to-seg.start) {
+		r := image.Rect(pt.X+x[0].Round(), y, pt.X+x[1].Round(), y+thickness)
+		draw.Draw(dst, r, ed.opts.Text, image.ZP, draw.Src)
+	}
+}
@@ -0,0 +1,98 @@
+package editor
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestPreedit(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("ab"))
+	ed.SetDot(address.Selection{From: address.Simple{0, 1}, To: address.Simple{0, 1}})
+
+	ed.SetPreedit("xyz", 1)
+	if got := ed.buffer.Lines[0].String(); got != "axyzb" {
+		t.Errorf("got line %q, wanted the composition text at dot", got)
+	}
+	if got := string(ed.Contents()); got != "ab" {
+		t.Errorf("got contents %q, wanted %q", got, "ab")
+	}
+	if ed.CanUndo() {
+		t.Error("composition text was recorded in history")
+	}
+
+	// the composition text is underlined, and the cursor is within it
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	ed.Draw(dst, dst.Rect)
+	x := ed.getPixelsAbs(address.Simple{0, 1}).X
+	if got := dst.RGBAAt(x+3, ed.fontHeight-1); got != (color.RGBA{A: 0xFF}) {
+		t.Errorf("got %v below the composition text, wanted an underline", got)
+	}
+	if got := ed.frame.sels; len(got) != 1 || got[0].From != (address.Simple{0, 2}) {
+		t.Errorf("got cursors %v, wanted one at the input method's cursor", got)
+	}
+
+	ed.SetPreedit("", 0)
+	if got := ed.buffer.Lines[0].String(); got != "ab" {
+		t.Errorf("got line %q after ending the composition", got)
+	}
+
+	// committed text is typed, and undone in one step
+	ed.SetPreedit("x", 1)
+	ed.CommitPreedit("字字")
+	if got := string(ed.Contents()); got != "a字字b" {
+		t.Errorf("got contents %q after commit, wanted %q", got, "a字字b")
+	}
+	if want := (address.Simple{0, 3}); ed.dot.To != want {
+		t.Errorf("got dot %v after commit, wanted %v", ed.dot.To, want)
+	}
+	ed.SendUndo()
+	if got := string(ed.Contents()); got != "ab" {
+		t.Errorf("got contents %q after undo, wanted %q", got, "ab")
+	}
+
+	// other input discards the composition
+	ed.SetPreedit("q", 1)
+	ed.SendKeyEvent(key.Event{Rune: 'z', Direction: key.DirPress})
+	if got := ed.buffer.Lines[0].String(); got != "azb" {
+		t.Errorf("got line %q after typing, wanted %q", got, "azb")
+	}
+}
+
+func TestPreeditMulti(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	ed.Load([]byte("ab cd\nef"))
+	ed.SetDot(address.Selection{From: address.Simple{1, 1}, To: address.Simple{1, 1}})
+	ed.AddSelection(address.Selection{From: address.Simple{0, 4}, To: address.Simple{0, 4}})
+	ed.AddSelection(address.Selection{From: address.Simple{0, 1}, To: address.Simple{0, 1}})
+
+	// selections following the composition text are moved past it
+	ed.SetPreedit("x\ny", 1)
+	want := []address.Selection{
+		{From: address.Simple{0, 1}, To: address.Simple{0, 1}},
+		{From: address.Simple{1, 4}, To: address.Simple{1, 4}},
+		{From: address.Simple{2, 1}, To: address.Simple{2, 1}},
+	}
+	if got := ed.Selections(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got selections %v while composing, wanted %v", got, want)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 60))
+	ed.Draw(dst, dst.Rect)
+	want[0] = address.Selection{From: address.Simple{0, 2}, To: address.Simple{0, 2}}
+	if got := ed.frame.sels; !reflect.DeepEqual(got, want) {
+		t.Errorf("got drawn selections %v, wanted %v", got, want)
+	}
+
+	// and moved back when it is committed
+	ed.CommitPreedit("z")
+	if got, want := string(ed.Contents()), "azb czd\nezf"; got != want {
+		t.Errorf("got contents %q after commit, wanted %q", got, want)
+	}
+}
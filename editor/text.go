@@ -7,22 +7,35 @@ import (
 
 // Load replaces the contents of the Editor's text buffer with s, and resets the Editor's history.
 func (ed *Editor) Load(s []byte) {
+	ed.preedit = preedit{}
 	last := len(ed.buffer.Lines) - 1
 	all := address.Selection{To: address.Simple{last, ed.buffer.Lines[last].RuneCount()}}
-	ed.dot = ed.buffer.ClearSel(all)
-	ed.dot.To = ed.buffer.InsertString(address.Simple{}, string(s))
+	ed.dot = ed.clearSel(all)
+	ed.dot.To = ed.insertString(address.Simple{}, string(s))
+	ed.extra = nil
 	ed.history = new(hist.History)
 	ed.uncommitted = nil
+	if ed.opts.Vi {
+		ed.vi.mode, ed.vi.pending, ed.vi.recording = viNormal, nil, false
+		ed.viClamp()
+	}
 	ed.dirty = true
 }
 
 // Contents returns the entire contents of the editor.
 func (ed *Editor) Contents() []byte {
-	return ed.buffer.Contents()
+	return ed.contents()
 }
 
 // Replace replaces the current selection with s, updating the Editor's history.
 func (ed *Editor) Replace(s string) {
+	ed.cancelPreedit()
+	if len(ed.extra) > 0 {
+		ed.putAll(s, true)
+		ed.commitTransformation()
+		ed.autoscroll()
+		return
+	}
 	ed.initTransformation()
 	ed.putString(s)
 	ed.commitTransformation()
@@ -36,9 +49,12 @@ func (ed *Editor) GetDot() address.Selection {
 
 func (ed *Editor) SetDot(a address.Selection) {
 	if a != ed.dot {
+		ed.cancelPreedit()
 		ed.initTransformation()
 		ed.commitTransformation()
 		ed.dot = a
+		ed.extra = nil
+		ed.lastAction = ""
 	}
 }
 
@@ -54,6 +70,7 @@ func (ed *Editor) LastAddress() address.Simple {
 // starting from the current selection, possibly wrapping around to the beginning
 // of the buffer. If there are no matches, the selection is unchanged.
 func (ed *Editor) FindNext(s string) (address.Selection, bool) {
+	ed.cancelPreedit()
 	if sel, ok := ed.buffer.Find(ed.dot.To, s); ok {
 		ed.dot = sel
 		ed.autoscroll()
@@ -65,6 +82,7 @@ func (ed *Editor) FindNext(s string) (address.Selection, bool) {
 
 // JumpTo sets the selection to the specified address, as define in sam(1).
 func (ed *Editor) JumpTo(addr string) bool {
+	ed.cancelPreedit()
 	if sel, ok := ed.buffer.JumpTo(ed.dot.To, addr); ok {
 		ed.dot = sel
 		ed.autoscroll()
@@ -77,7 +95,42 @@ func (ed *Editor) JumpTo(addr string) bool {
 // putString replaces the current selection with s, and selects
 // the results.
 func (ed *Editor) putString(s string) {
-	ed.buffer.ClearSel(ed.dot)
-	addr := ed.buffer.InsertString(ed.dot.From, s)
+	ed.clearSel(ed.dot)
+	addr := ed.insertString(ed.dot.From, s)
 	ed.dot.To = addr
 }
+
+// clearSel deletes the text in sel from the buffer, and returns
+// the resulting empty selection.
+func (ed *Editor) clearSel(sel address.Selection) address.Selection {
+	if sel.IsEmpty() {
+		return sel
+	}
+	sel.From, sel.To = ed.buffer.ClampSimple(sel.From), ed.buffer.ClampSimple(sel.To)
+	ret := ed.buffer.ClearSel(sel)
+	ed.linesChanged(sel.From.Row, sel.To.Row-sel.From.Row+1, 1)
+	return ret
+}
+
+// insertString inserts s into the buffer at a, and returns the
+// address following the inserted text.
+func (ed *Editor) insertString(a address.Simple, s string) address.Simple {
+	a = ed.buffer.ClampSimple(a)
+	end := ed.buffer.InsertString(a, s)
+	ed.linesChanged(a.Row, 1, end.Row-a.Row+1)
+	return end
+}
+
+// linesChanged records that n lines of the buffer starting at row
+// were replaced by m lines.
+func (ed *Editor) linesChanged(row, n, m int) {
+	ed.hl.changed(row, n, m)
+	ed.wrap.changed(row, n, m)
+	ed.elasticChanged(row, n, m)
+	if n == m && !ed.opts.Wrap {
+		ed.damage.rows(row, row+m)
+	} else {
+		// following rows have moved
+		ed.damage.rows(row, toEnd)
+	}
+}
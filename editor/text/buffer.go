@@ -40,6 +40,40 @@ func (b *Buffer) PrevSimple(a address.Simple) address.Simple {
 	return a
 }
 
+// NextWord returns the address at the end of the word following a,
+// skipping over any non-word characters, including newlines.
+func (b *Buffer) NextWord(a address.Simple) address.Simple {
+	for a != b.LastAddress() && isWordSep(b.RuneAt(a)) {
+		a = b.NextSimple(a)
+	}
+	for a != b.LastAddress() && !isWordSep(b.RuneAt(a)) {
+		a = b.NextSimple(a)
+	}
+	return a
+}
+
+// PrevWord returns the address at the beginning of the word preceding a,
+// skipping over any non-word characters, including newlines.
+func (b *Buffer) PrevWord(a address.Simple) address.Simple {
+	for a != (address.Simple{}) && isWordSep(b.RuneAt(b.PrevSimple(a))) {
+		a = b.PrevSimple(a)
+	}
+	for a != (address.Simple{}) && !isWordSep(b.RuneAt(b.PrevSimple(a))) {
+		a = b.PrevSimple(a)
+	}
+	return a
+}
+
+// RuneAt returns the rune following a, or '\n' if a is at the end of a line.
+func (b *Buffer) RuneAt(a address.Simple) rune {
+	line := b.Lines[a.Row].s[b.Lines[a.Row].elemFromCol(a.Col):]
+	if len(line) == 0 {
+		return '\n'
+	}
+	r, _ := utf8.DecodeRune(line)
+	return r
+}
+
 func (b *Buffer) Contents() []byte {
 	var buf bytes.Buffer
 	for _, l := range b.Lines {
@@ -65,6 +99,11 @@ func (b *Buffer) fixAddr(a address.Simple) address.Simple {
 	return a
 }
 
+// ClampSimple returns the valid address in the buffer nearest to a.
+func (b *Buffer) ClampSimple(a address.Simple) address.Simple {
+	return b.fixAddr(a)
+}
+
 func (b *Buffer) GetSel(sel address.Selection) string {
 	if sel.IsEmpty() {
 		return ""
@@ -0,0 +1,855 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// The vi layer is enabled by OptionSet.Vi. In insert mode, keys are
+// handled as usual, except for escape, which returns to normal mode.
+// In normal and visual mode, keys are collected until they form a
+// complete command, in the form [count][operator[count]]motion or
+// [count]command.
+
+type viMode int
+
+const (
+	viNormal viMode = iota
+	viInsert
+	viVisual
+	viVisualLine
+)
+
+type viState struct {
+	enabled bool // opts.Vi, as of the last call to SetOpts
+	mode    viMode
+	pending []key.Event    // the keys of an incomplete command
+	cur     address.Simple // the cursor in visual mode
+	anchor  address.Simple // the fixed end of a visual selection
+
+	reg      string // the unnamed register
+	regLines bool   // reg holds whole lines
+
+	change    []key.Event // the keys of the change being recorded
+	recording bool        // the change continues until insert mode is left
+	last      []key.Event // the keys of the last complete change, repeated by "."
+
+	// the text typed in insert mode is repeated when insert mode is
+	// left, if an insert command was given a count
+	insertCount int
+	insertKeys  []key.Event // the keys typed since insert mode was entered
+	insertLine  bool        // each repetition begins a new line, as for o and O
+}
+
+const (
+	viOperators = "dcy<>"
+	viMotions   = "hjklwbe0^$G%"
+	viCommands  = "iaIAoOxXpPDCuvV.\x12" // \x12 is ^R
+	viChanges   = "iaIAoOxXpPDC"
+)
+
+// ViMode returns the name of the current vi mode: "normal", "insert",
+// "visual", or "visual line". If the vi layer is disabled, ViMode
+// returns the empty string.
+func (ed *Editor) ViMode() string {
+	if !ed.opts.Vi {
+		return ""
+	}
+	return [...]string{"normal", "insert", "visual", "visual line"}[ed.vi.mode]
+}
+
+// viKey handles e if the vi layer wants it, and reports whether it did.
+func (ed *Editor) viKey(e key.Event) bool {
+	if ed.vi.mode == viInsert {
+		if ed.vi.recording {
+			ed.vi.change = append(ed.vi.change, e)
+		}
+		if e.Code != key.CodeEscape {
+			ed.vi.insertKeys = append(ed.vi.insertKeys, e)
+			return false
+		}
+		ed.viRepeatInsert()
+		ed.commitTransformation()
+		ed.vi.mode = viNormal
+		if ed.vi.recording {
+			ed.vi.last, ed.vi.recording = ed.vi.change, false
+		}
+		if ed.dot.From.Col > 0 {
+			ed.dot.From.Col--
+		}
+		ed.viClamp()
+		return true
+	}
+
+	ed.commitTransformation()
+	if e.Code == key.CodeEscape {
+		ed.vi.pending = nil
+		if ed.vi.mode != viNormal {
+			ed.vi.mode = viNormal
+			ed.dot = address.Selection{From: ed.vi.cur, To: ed.vi.cur}
+		}
+		ed.viClamp()
+		return true
+	}
+
+	r := viRune(e)
+	if r == 0 {
+		// let chords like Meta-C through to the keymap
+		return e.Modifiers&key.ModMeta == 0
+	}
+	ed.vi.pending = append(ed.vi.pending, e)
+	var s []rune
+	for _, e := range ed.vi.pending {
+		s = append(s, viRune(e))
+	}
+	c, complete, ok := parseVi(string(s), ed.vi.mode != viNormal)
+	if !ok {
+		ed.vi.pending = nil
+		return true
+	}
+	if !complete {
+		return true
+	}
+	keys := ed.vi.pending
+	ed.vi.pending = nil
+
+	if ed.vi.mode == viNormal {
+		ed.viNormal(c)
+		if c.op != 0 && c.op != 'y' || strings.ContainsRune(viChanges, []rune(c.verb)[0]) {
+			ed.vi.change = keys
+			if ed.vi.mode == viInsert {
+				ed.vi.recording = true
+			} else {
+				ed.vi.last = keys
+			}
+		}
+	} else {
+		ed.viVisual(c)
+	}
+	ed.autoscroll()
+	return true
+}
+
+// viRune returns the rune used for e by the vi command parser, or 0.
+func viRune(e key.Event) rune {
+	switch {
+	case e.Modifiers == key.ModControl && e.Code == key.CodeR:
+		return '\x12'
+	case e.Code == key.CodeLeftArrow:
+		return 'h'
+	case e.Code == key.CodeDownArrow:
+		return 'j'
+	case e.Code == key.CodeUpArrow:
+		return 'k'
+	case e.Code == key.CodeRightArrow:
+		return 'l'
+	case e.Modifiers&(key.ModControl|key.ModMeta) != 0 || !unicode.IsGraphic(e.Rune):
+		return 0
+	}
+	return e.Rune
+}
+
+type viCmd struct {
+	count int    // 0 if no count was given
+	op    rune   // an operator, or 0
+	verb  string // a motion, a command, or the operator repeated
+}
+
+// parseVi parses s as a vi command. If s is a valid prefix of a command,
+// ok is true, and complete reports whether s is a whole command. In
+// visual mode, operators apply to the selection and take no motion.
+func parseVi(s string, visual bool) (c viCmd, complete, ok bool) {
+	rs := []rune(s)
+	num := func() int {
+		n := 0
+		for len(rs) > 0 && ('1' <= rs[0] && rs[0] <= '9' || n > 0 && rs[0] == '0') {
+			n = n*10 + int(rs[0]-'0')
+			rs = rs[1:]
+		}
+		return n
+	}
+
+	c.count = num()
+	if len(rs) == 0 {
+		return c, false, true
+	}
+	if strings.ContainsRune(viOperators, rs[0]) {
+		c.op, rs = rs[0], rs[1:]
+		if visual {
+			return c, len(rs) == 0, len(rs) == 0
+		}
+		if n := num(); n > 0 {
+			if c.count == 0 {
+				c.count = 1
+			}
+			c.count *= n
+		}
+		if len(rs) == 0 {
+			return c, false, true
+		}
+		if rs[0] == c.op {
+			c.verb = string(c.op)
+			return c, len(rs) == 1, len(rs) == 1
+		}
+	}
+
+	switch rs[0] {
+	case 'g':
+		if len(rs) == 1 {
+			return c, false, true
+		}
+		c.verb = "gg"
+		return c, true, rs[1] == 'g' && len(rs) == 2
+	case 'f', 't', 'F', 'T':
+		if len(rs) == 1 {
+			return c, false, true
+		}
+		c.verb = string(rs)
+		return c, true, len(rs) == 2
+	}
+	c.verb = string(rs)
+	if len(rs) != 1 {
+		return c, false, false
+	}
+	ok = strings.ContainsRune(viMotions, rs[0]) || c.op == 0 && strings.ContainsRune(viCommands, rs[0])
+	return c, ok, ok
+}
+
+// viNormal executes c in normal mode.
+func (ed *Editor) viNormal(c viCmd) {
+	cur := ed.dot.From
+	n := c.count
+	if n == 0 {
+		n = 1
+	}
+
+	if c.op != 0 {
+		verb := c.verb
+		if verb == string(c.op) {
+			// dd, cc, yy, >>, <<
+			to := cur
+			to.Row += n - 1
+			ed.viOperate(c.op, address.Selection{From: cur, To: ed.buffer.ClampSimple(to)}, true)
+			return
+		}
+		if c.op == 'c' && verb == "w" {
+			// cw changes to the end of the word, like ce
+			verb = "e"
+		}
+		to, inclusive, linewise, ok := ed.viMotion(cur, verb, c.count)
+		if !ok {
+			return
+		}
+		if verb == "w" && to.Row > cur.Row {
+			// don't operate on the newline following the last word
+			to = address.Simple{Row: to.Row - 1, Col: ed.buffer.Lines[to.Row-1].RuneCount()}
+		}
+		sel := address.Selection{From: cur, To: to}
+		if to.LessThan(cur) {
+			sel = address.Selection{From: to, To: cur}
+		}
+		if inclusive && sel.To.Col < ed.buffer.Lines[sel.To.Row].RuneCount() {
+			sel.To.Col++
+		}
+		ed.viOperate(c.op, sel, linewise)
+		return
+	}
+
+	if to, _, _, ok := ed.viMotion(cur, c.verb, c.count); ok {
+		ed.dot = address.Selection{From: to, To: to}
+		ed.viClamp()
+		return
+	}
+
+	line := ed.buffer.Lines[cur.Row]
+	switch c.verb {
+	case "i", "a", "I", "A", "o", "O":
+		ed.vi.insertCount = n
+		ed.vi.insertKeys = nil
+		ed.vi.insertLine = c.verb == "o" || c.verb == "O"
+	}
+	switch c.verb {
+	case "i":
+		ed.vi.mode = viInsert
+		ed.dot.To = ed.dot.From
+	case "a":
+		if line.RuneCount() > 0 {
+			cur.Col++
+		}
+		ed.viInsertAt(cur)
+	case "I":
+		ed.viInsertAt(ed.viFirstNonBlank(cur.Row))
+	case "A":
+		ed.viInsertAt(address.Simple{Row: cur.Row, Col: line.RuneCount()})
+	case "o":
+		// the new line and the text inserted on it form a single
+		// transformation
+		a := address.Simple{Row: cur.Row, Col: line.RuneCount()}
+		ed.dot = address.Selection{From: a, To: a}
+		ed.initTransformation()
+		ed.viOpenLine()
+		ed.vi.mode = viInsert
+	case "O":
+		indent := ed.getIndentation()
+		ed.viReplace(address.Selection{From: address.Simple{Row: cur.Row}, To: address.Simple{Row: cur.Row}}, indent+"\n")
+		ed.viInsertAt(address.Simple{Row: cur.Row, Col: utf8.RuneCountInString(indent)})
+	case "x":
+		to := cur
+		to.Col += n
+		if to.Col > line.RuneCount() {
+			to.Col = line.RuneCount()
+		}
+		ed.viOperate('d', address.Selection{From: cur, To: to}, false)
+	case "X":
+		from := cur
+		from.Col -= n
+		if from.Col < 0 {
+			from.Col = 0
+		}
+		ed.viOperate('d', address.Selection{From: from, To: cur}, false)
+	case "D", "C":
+		to := address.Simple{Row: cur.Row, Col: line.RuneCount()}
+		ed.viOperate(unicode.ToLower(rune(c.verb[0])), address.Selection{From: cur, To: to}, false)
+	case "p", "P":
+		ed.viPut(c.verb == "p", n)
+	case "u":
+		for i := 0; i < n; i++ {
+			ed.undo()
+		}
+		ed.viClamp()
+	case "\x12":
+		for i := 0; i < n; i++ {
+			ed.redo()
+		}
+		ed.viClamp()
+	case "v", "V":
+		ed.vi.mode = viVisual
+		if c.verb == "V" {
+			ed.vi.mode = viVisualLine
+		}
+		ed.vi.anchor, ed.vi.cur = cur, cur
+		ed.viSelectVisual()
+	case ".":
+		keys := ed.vi.last
+		if len(keys) == 0 {
+			return
+		}
+		if c.count > 0 {
+			keys = viWithCount(keys, c.count)
+		}
+		for _, e := range keys {
+			ed.handleKeyEvent(e)
+		}
+		ed.vi.pending = nil
+	}
+}
+
+// viVisual executes c in visual mode.
+func (ed *Editor) viVisual(c viCmd) {
+	if c.op == 0 {
+		if to, _, _, ok := ed.viMotion(ed.vi.cur, c.verb, c.count); ok {
+			ed.vi.cur = to
+			ed.viSelectVisual()
+			return
+		}
+	}
+
+	op := c.op
+	switch c.verb {
+	case "x", "D":
+		op = 'd'
+	case "C":
+		op = 'c'
+	case "v", "V":
+		mode := viVisual
+		if c.verb == "V" {
+			mode = viVisualLine
+		}
+		if ed.vi.mode == mode {
+			ed.vi.mode = viNormal
+			ed.dot = address.Selection{From: ed.vi.cur, To: ed.vi.cur}
+			ed.viClamp()
+		} else {
+			ed.vi.mode = mode
+			ed.viSelectVisual()
+		}
+		return
+	}
+	if op == 0 {
+		return
+	}
+	sel := ed.dot
+	linewise := ed.vi.mode == viVisualLine
+	if linewise {
+		sel = address.Selection{From: ed.vi.anchor, To: ed.vi.cur}
+		if sel.To.LessThan(sel.From) {
+			sel.From, sel.To = sel.To, sel.From
+		}
+	}
+	keys := ed.viVisualKeys(op)
+	ed.vi.mode = viNormal
+	ed.viOperate(op, sel, linewise)
+	if op != 'y' {
+		ed.vi.change = keys
+		if ed.vi.mode == viInsert {
+			ed.vi.recording = true
+		} else {
+			ed.vi.last = keys
+		}
+	}
+}
+
+// viVisualKeys returns the keys which apply op to the visual selection
+// from normal mode, and which "." repeats: they select as many lines,
+// and characters of the last line, starting at the cursor.
+func (ed *Editor) viVisualKeys(op rune) []key.Event {
+	from, to := ed.vi.anchor, ed.vi.cur
+	if to.LessThan(from) {
+		from, to = to, from
+	}
+	s := "v"
+	if ed.vi.mode == viVisualLine {
+		s = "V"
+	}
+	if to.Row > from.Row {
+		s += strconv.Itoa(to.Row-from.Row) + "j"
+		if ed.vi.mode == viVisual {
+			from.Col = 0
+			s += "0"
+		}
+	}
+	if ed.vi.mode == viVisual && to.Col > from.Col {
+		s += strconv.Itoa(to.Col-from.Col) + "l"
+	}
+	s += string(op)
+	keys := make([]key.Event, 0, len(s))
+	for _, r := range s {
+		keys = append(keys, key.Event{Rune: r, Direction: key.DirPress})
+	}
+	return keys
+}
+
+// viWithCount returns keys, a command, with its count replaced by n.
+func viWithCount(keys []key.Event, n int) []key.Event {
+	i := 0
+	for ; i < len(keys); i++ {
+		r := viRune(keys[i])
+		if !('1' <= r && r <= '9' || i > 0 && r == '0') {
+			break
+		}
+	}
+	var counted []key.Event
+	for _, r := range strconv.Itoa(n) {
+		counted = append(counted, key.Event{Rune: r, Direction: key.DirPress})
+	}
+	return append(counted, keys[i:]...)
+}
+
+// viOpenLine types a newline, followed by the indentation of the
+// current line if auto-indentation is enabled. The transformation must
+// have been initialized.
+func (ed *Editor) viOpenLine() {
+	s := "\n"
+	if ed.opts.AutoIndent {
+		s += ed.getIndentation()
+	}
+	ed.typeString(s)
+}
+
+// viRepeatInsert repeats the text typed in insert mode, so that it is
+// inserted as many times as the count of the insert command.
+func (ed *Editor) viRepeatInsert() {
+	n, keys := ed.vi.insertCount, ed.vi.insertKeys
+	ed.vi.insertCount, ed.vi.insertKeys = 0, nil
+	if n <= 1 {
+		return
+	}
+	recording := ed.vi.recording
+	ed.vi.recording = false
+	for i := 1; i < n; i++ {
+		ed.initTransformation()
+		if ed.vi.insertLine {
+			ed.viOpenLine()
+		}
+		for _, e := range keys {
+			ed.handleKeyEvent(e)
+		}
+	}
+	ed.vi.recording = recording
+}
+
+// viSelectVisual sets dot to the visual selection, which includes the
+// characters at both of its ends.
+func (ed *Editor) viSelectVisual() {
+	from, to := ed.vi.anchor, ed.vi.cur
+	if to.LessThan(from) {
+		from, to = to, from
+	}
+	if ed.vi.mode == viVisualLine {
+		from.Col = 0
+		to.Col = ed.buffer.Lines[to.Row].RuneCount()
+	}
+	ed.dot = address.Selection{From: from, To: ed.buffer.NextSimple(to)}
+}
+
+// viOperate applies the operator op to sel. If linewise is true, op
+// applies to every line which sel touches.
+func (ed *Editor) viOperate(op rune, sel address.Selection, linewise bool) {
+	last := len(ed.buffer.Lines) - 1
+	if !linewise {
+		ed.vi.reg, ed.vi.regLines = ed.buffer.GetSel(sel), false
+		switch op {
+		case 'd':
+			ed.viReplace(sel, "")
+		case 'c':
+			ed.viChange(sel)
+			return
+		case '<', '>':
+			ed.viShift(op, sel.From.Row, sel.To.Row)
+			return
+		}
+		ed.dot = address.Selection{From: sel.From, To: sel.From}
+		ed.viClamp()
+		return
+	}
+
+	r1, r2 := sel.From.Row, sel.To.Row
+	lines := address.Selection{
+		From: address.Simple{Row: r1},
+		To:   address.Simple{Row: r2, Col: ed.buffer.Lines[r2].RuneCount()},
+	}
+	ed.vi.reg, ed.vi.regLines = ed.buffer.GetSel(lines)+"\n", true
+	switch op {
+	case 'd':
+		if r2 < last {
+			lines.To = address.Simple{Row: r2 + 1}
+		} else if r1 > 0 {
+			lines.From = address.Simple{Row: r1 - 1, Col: ed.buffer.Lines[r1-1].RuneCount()}
+		}
+		ed.viReplace(lines, "")
+		if r1 > len(ed.buffer.Lines)-1 {
+			r1 = len(ed.buffer.Lines) - 1
+		}
+	case 'c':
+		lines.From = ed.viFirstNonBlank(r1)
+		ed.viChange(lines)
+		return
+	case '<', '>':
+		ed.viShift(op, r1, r2)
+		return
+	case 'y':
+		// the cursor stays in its column
+		ed.dot = address.Selection{From: ed.buffer.ClampSimple(address.Simple{Row: r1, Col: ed.dot.From.Col})}
+		ed.viClamp()
+		return
+	}
+	a := ed.viFirstNonBlank(r1)
+	ed.dot = address.Selection{From: a, To: a}
+}
+
+// viChange deletes sel and enters insert mode. The deletion and the
+// text inserted afterwards form a single transformation.
+func (ed *Editor) viChange(sel address.Selection) {
+	ed.dot = sel
+	ed.initTransformation()
+	ed.dot = ed.clearSel(sel)
+	ed.vi.mode = viInsert
+}
+
+// viShift indents (op is '>') or unindents (op is '<') lines r1
+// through r2 by one tab.
+func (ed *Editor) viShift(op rune, r1, r2 int) {
+	sel := address.Selection{
+		From: address.Simple{Row: r1},
+		To:   address.Simple{Row: r2, Col: ed.buffer.Lines[r2].RuneCount()},
+	}
+	lines := strings.Split(ed.buffer.GetSel(sel), "\n")
+	for i, l := range lines {
+		if op == '>' && l != "" {
+			lines[i] = "\t" + l
+		} else if op == '<' {
+			lines[i] = strings.TrimPrefix(l, "\t")
+		}
+	}
+	ed.viReplace(sel, strings.Join(lines, "\n"))
+	a := ed.viFirstNonBlank(r1)
+	ed.dot = address.Selection{From: a, To: a}
+}
+
+// viPut inserts n copies of the register after the cursor, or before
+// it if after is false.
+func (ed *Editor) viPut(after bool, n int) {
+	if ed.vi.reg == "" {
+		return
+	}
+	s := strings.Repeat(ed.vi.reg, n)
+	cur := ed.dot.From
+	if !ed.vi.regLines {
+		if after && ed.buffer.Lines[cur.Row].RuneCount() > 0 {
+			cur.Col++
+		}
+		ed.viReplace(address.Selection{From: cur, To: cur}, s)
+		ed.dot.From = ed.buffer.PrevSimple(ed.dot.To)
+		ed.viClamp()
+		return
+	}
+
+	row := cur.Row
+	if after {
+		row++
+	}
+	a := address.Simple{Row: row}
+	if row > len(ed.buffer.Lines)-1 {
+		// there is no following line to insert in front of
+		a = ed.buffer.LastAddress()
+		s = "\n" + strings.TrimSuffix(s, "\n")
+	}
+	ed.viReplace(address.Selection{From: a, To: a}, s)
+	a = ed.viFirstNonBlank(row)
+	ed.dot = address.Selection{From: a, To: a}
+}
+
+// viReplace replaces sel with s as a single transformation.
+func (ed *Editor) viReplace(sel address.Selection, s string) {
+	ed.dot = sel
+	ed.initTransformation()
+	ed.putString(s)
+	ed.commitTransformation()
+}
+
+func (ed *Editor) viInsertAt(a address.Simple) {
+	ed.dot = address.Selection{From: a, To: a}
+	ed.vi.mode = viInsert
+}
+
+// viClamp collapses dot, and keeps the cursor off the end of the line,
+// as vi does in normal mode.
+func (ed *Editor) viClamp() {
+	a := ed.dot.From
+	if n := ed.buffer.Lines[a.Row].RuneCount(); a.Col >= n && n > 0 {
+		a.Col = n - 1
+	}
+	ed.dot = address.Selection{From: a, To: a}
+}
+
+func (ed *Editor) viFirstNonBlank(row int) address.Simple {
+	a := address.Simple{Row: row}
+	for _, r := range ed.buffer.Lines[row].String() {
+		if !unicode.IsSpace(r) {
+			break
+		}
+		a.Col++
+	}
+	return a
+}
+
+// viMotion returns the address reached by moving from a by the motion m,
+// count times, and reports whether the motion is inclusive of the
+// character it lands on or moves by whole lines. If m is not a motion
+// or can't be made, ok is false.
+func (ed *Editor) viMotion(a address.Simple, m string, count int) (to address.Simple, inclusive, linewise, ok bool) {
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	buf := ed.buffer
+	last := len(buf.Lines) - 1
+	lineLen := buf.Lines[a.Row].RuneCount()
+
+	switch m[0] {
+	case 'h':
+		a.Col -= n
+		if a.Col < 0 {
+			a.Col = 0
+		}
+	case 'l':
+		a.Col += n
+		if a.Col > lineLen {
+			a.Col = lineLen
+		}
+	case 'j', 'k':
+		if m[0] == 'k' {
+			n = -n
+		}
+		a = buf.ClampSimple(address.Simple{Row: a.Row + n, Col: a.Col})
+		return a, false, true, true
+	case 'w':
+		for i := 0; i < n; i++ {
+			a = ed.viNextWord(a)
+		}
+	case 'b':
+		for i := 0; i < n; i++ {
+			a = ed.viPrevWord(a)
+		}
+	case 'e':
+		for i := 0; i < n; i++ {
+			a = ed.viWordEnd(a)
+		}
+		return a, true, false, true
+	case '0':
+		a.Col = 0
+	case '^':
+		a = ed.viFirstNonBlank(a.Row)
+	case '$':
+		a.Row += n - 1
+		if a.Row > last {
+			a.Row = last
+		}
+		a.Col = buf.Lines[a.Row].RuneCount() - 1
+		if a.Col < 0 {
+			a.Col = 0
+		}
+		return a, true, false, true
+	case 'G':
+		row := last
+		if count > 0 && count-1 < last {
+			row = count - 1
+		}
+		return ed.viFirstNonBlank(row), false, true, true
+	case 'g':
+		row := 0
+		if count > 0 {
+			row = count - 1
+			if row > last {
+				row = last
+			}
+		}
+		return ed.viFirstNonBlank(row), false, true, true
+	case 'f', 't', 'F', 'T':
+		line := []rune(buf.Lines[a.Row].String())
+		target, _ := utf8.DecodeRuneInString(m[1:])
+		col := a.Col
+		for i := 0; i < n; i++ {
+			found := false
+			if m[0] == 'f' || m[0] == 't' {
+				for col++; col < len(line); col++ {
+					if line[col] == target {
+						found = true
+						break
+					}
+				}
+			} else {
+				for col--; col >= 0; col-- {
+					if line[col] == target {
+						found = true
+						break
+					}
+				}
+			}
+			if !found {
+				return a, false, false, false
+			}
+		}
+		switch m[0] {
+		case 't':
+			col--
+		case 'T':
+			col++
+		}
+		a.Col = col
+		return a, m[0] == 'f' || m[0] == 't', false, true
+	case '%':
+		to, ok := ed.viMatch(a)
+		return to, true, false, ok
+	default:
+		return a, false, false, false
+	}
+	return a, false, false, true
+}
+
+func viClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case isWordChar(r):
+		return 1
+	}
+	return 2
+}
+
+// viNextWord returns the start of the word following a.
+func (ed *Editor) viNextWord(a address.Simple) address.Simple {
+	buf := ed.buffer
+	end := buf.LastAddress()
+	if c := viClass(buf.RuneAt(a)); c != 0 {
+		for a != end && viClass(buf.RuneAt(a)) == c {
+			a = buf.NextSimple(a)
+		}
+	}
+	for a != end && viClass(buf.RuneAt(a)) == 0 {
+		a = buf.NextSimple(a)
+		if a.Col == 0 && buf.Lines[a.Row].RuneCount() == 0 {
+			break // an empty line counts as a word
+		}
+	}
+	return a
+}
+
+// viWordEnd returns the end of the word following a.
+func (ed *Editor) viWordEnd(a address.Simple) address.Simple {
+	buf := ed.buffer
+	end := buf.LastAddress()
+	a = buf.NextSimple(a)
+	for a != end && viClass(buf.RuneAt(a)) == 0 {
+		a = buf.NextSimple(a)
+	}
+	c := viClass(buf.RuneAt(a))
+	for next := buf.NextSimple(a); next != end && viClass(buf.RuneAt(next)) == c && c != 0; next = buf.NextSimple(a) {
+		a = next
+	}
+	return a
+}
+
+// viPrevWord returns the start of the word preceding a.
+func (ed *Editor) viPrevWord(a address.Simple) address.Simple {
+	buf := ed.buffer
+	a = buf.PrevSimple(a)
+	for a != (address.Simple{}) && viClass(buf.RuneAt(a)) == 0 {
+		a = buf.PrevSimple(a)
+	}
+	c := viClass(buf.RuneAt(a))
+	for a.Col > 0 && viClass(buf.RuneAt(buf.PrevSimple(a))) == c {
+		a = buf.PrevSimple(a)
+	}
+	return a
+}
+
+// viMatch returns the address of the bracket matching the first
+// bracket found at or after a on its line.
+func (ed *Editor) viMatch(a address.Simple) (address.Simple, bool) {
+	const brackets = "([{)]}"
+	buf := ed.buffer
+	line := []rune(buf.Lines[a.Row].String())
+	for ; a.Col < len(line) && !strings.ContainsRune(brackets, line[a.Col]); a.Col++ {
+	}
+	if a.Col == len(line) {
+		return a, false
+	}
+	i := strings.IndexRune(brackets, line[a.Col])
+	open, close := rune(brackets[i%3]), rune(brackets[i%3+3])
+	next := buf.NextSimple
+	if i >= 3 {
+		open, close = close, open
+		next = buf.PrevSimple
+	}
+
+	depth := 0
+	for prev := (address.Simple{-1, -1}); a != prev; prev, a = a, next(a) {
+		switch buf.RuneAt(a) {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return a, true
+			}
+		}
+	}
+	return a, false
+}
@@ -0,0 +1,112 @@
+package editor
+
+import (
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/mobile/event/key"
+)
+
+// sendVi sends s to ed as key events. A '\x1b' in s is sent as escape.
+func sendVi(ed *Editor, s string) {
+	for _, r := range s {
+		if r == '\x1b' {
+			ed.SendKeyEvent(key.Event{Code: key.CodeEscape})
+		} else {
+			ed.SendKeyEvent(key.Event{Rune: r})
+		}
+	}
+}
+
+func TestVi(t *testing.T) {
+	const text = "the quick brown fox\njumps over (the lazy) dog\n\tend"
+	cases := []struct {
+		keys string
+		want string
+	}{
+		{"dw", "quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"2dw", "brown fox\njumps over (the lazy) dog\n\tend"},
+		{"wd$", "the \njumps over (the lazy) dog\n\tend"},
+		{"3x", " quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"dd", "jumps over (the lazy) dog\n\tend"},
+		{"jddp", "the quick brown fox\n\tend\njumps over (the lazy) dog"},
+		{"Gdd", "the quick brown fox\njumps over (the lazy) dog"},
+		{"2dj", ""},
+		{"cwa\x1b", "a quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"cwa\x1bw.", "a a brown fox\njumps over (the lazy) dog\n\tend"},
+		{"ix\x1bu", text},
+		{"cwab\x1bu", text},
+		{"dd2xu", "jumps over (the lazy) dog\n\tend"},
+		{"Ax\x1bjj.", "the quick brown foxx\njumps over (the lazy) dog\n\tendx"},
+		{"jf(d%", "the quick brown fox\njumps over  dog\n\tend"},
+		{"jdt)", "the quick brown fox\n) dog\n\tend"},
+		{"yyjP", "the quick brown fox\nthe quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{">>", "\tthe quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"G<<", "the quick brown fox\njumps over (the lazy) dog\nend"},
+		{"wvlld", "the ck brown fox\njumps over (the lazy) dog\n\tend"},
+		{"Vjd", "\tend"},
+		{"ox\x1b", "the quick brown fox\nx\njumps over (the lazy) dog\n\tend"},
+		{"$a!\x1b", "the quick brown fox!\njumps over (the lazy) dog\n\tend"},
+		{"ggeD", "th\njumps over (the lazy) dog\n\tend"},
+		{"3ix\x1b", "xxxthe quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"2Aab\x1b", "the quick brown foxabab\njumps over (the lazy) dog\n\tend"},
+		{"2ox\x1b", "the quick brown fox\nx\nx\njumps over (the lazy) dog\n\tend"},
+		{"ix\x1b3.", "xxxxthe quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"dw2.", "fox\njumps over (the lazy) dog\n\tend"},
+		{"2ix\x1bu", text},
+		{"ox\x1bu", text},
+		{"wyyP", "the quick brown fox\nthe quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"2.x", "he quick brown fox\njumps over (the lazy) dog\n\tend"},
+		{"wvlldw.", "the ck wn fox\njumps over (the lazy) dog\n\tend"},
+		{"wvlcX\x1bw.", "the Xick Xown fox\njumps over (the lazy) dog\n\tend"},
+		{"Vd.", "\tend"},
+	}
+	for i, c := range cases {
+		opts := *SimpleTheme
+		opts.Vi = true
+		ed := NewEditor(basicfont.Face7x13, &opts)
+		ed.Load([]byte(text))
+		sendVi(ed, c.keys)
+		if got := string(ed.Contents()); got != c.want {
+			t.Errorf("case %d (%q):\ngot:    %q\nwanted: %q", i, c.keys, got, c.want)
+		}
+		if ed.ViMode() != "normal" {
+			t.Errorf("case %d (%q): got mode %q, wanted normal", i, c.keys, ed.ViMode())
+		}
+	}
+}
+
+func TestViYankCursor(t *testing.T) {
+	opts := *SimpleTheme
+	opts.Vi = true
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("the quick brown fox\njumps"))
+	sendVi(ed, "wwyy")
+	if got, want := ed.dot.From, (address.Simple{Row: 0, Col: 10}); got != want {
+		t.Errorf("got cursor %v after yy, wanted %v", got, want)
+	}
+	sendVi(ed, "yb")
+	if got, want := ed.dot.From, (address.Simple{Row: 0, Col: 4}); got != want {
+		t.Errorf("got cursor %v after yb, wanted %v", got, want)
+	}
+}
+
+func TestViEnter(t *testing.T) {
+	opts := *SimpleTheme
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("abc\ndef"))
+
+	// enabling vi, or loading text in normal mode, leaves a cursor
+	opts.Vi = true
+	ed.SetOpts(&opts)
+	want := address.Selection{}
+	if ed.dot != want {
+		t.Errorf("got dot %v after enabling vi, wanted %v", ed.dot, want)
+	}
+	ed.Load([]byte("ghi"))
+	if ed.dot != want {
+		t.Errorf("got dot %v after Load, wanted %v", ed.dot, want)
+	}
+}
@@ -0,0 +1,179 @@
+package editor
+
+import (
+	"sort"
+	"unicode"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// wrapCache holds the layout of buffer lines into display lines
+// when the Wrap option is set.
+type wrapCache struct {
+	width  fixed.Int26_6 // the width at which lines were wrapped
+	starts [][]int       // for each row, the columns at which its display lines start; nil if stale
+	first  []int         // for each row, the index of its first display line; nil if stale
+}
+
+// changed records that n lines starting at row were replaced by m lines.
+func (wc *wrapCache) changed(row, n, m int) {
+	if wc.starts == nil {
+		return
+	}
+	if n == m {
+		for i := row; i < row+m; i++ {
+			wc.starts[i] = nil
+		}
+	} else {
+		starts := make([][]int, len(wc.starts)-n+m)
+		copy(starts, wc.starts[:row])
+		copy(starts[row+m:], wc.starts[row+n:])
+		wc.starts = starts
+	}
+	wc.first = nil
+}
+
+// noWrap is the layout of a line which is not wrapped.
+var noWrap = []int{0}
+
+// wrapping reports whether lines are wrapped, and ensures that the
+// wrap cache matches the current width of the text area.
+func (ed *Editor) wrapping() bool {
+	if !ed.opts.Wrap || ed.textWidth() <= 0 {
+		return false
+	}
+	width := fixed.I(ed.textWidth())
+	if width != ed.wrap.width || len(ed.wrap.starts) != len(ed.buffer.Lines) {
+		ed.wrap = wrapCache{
+			width:  width,
+			starts: make([][]int, len(ed.buffer.Lines)),
+		}
+	}
+	return true
+}
+
+// lineStarts returns the columns at which the display lines of row start.
+func (ed *Editor) lineStarts(row int) []int {
+	if !ed.wrapping() {
+		return noWrap
+	}
+	if ed.wrap.starts[row] == nil {
+		ed.wrap.starts[row] = ed.wrapLine(ed.buffer.Lines[row].String(), ed.wrap.width)
+	}
+	return ed.wrap.starts[row]
+}
+
+// displayLine returns the index of the first display line of row.
+func (ed *Editor) displayLine(row int) int {
+	if !ed.wrapping() {
+		return row
+	}
+	if ed.wrap.first == nil {
+		ed.wrap.first = make([]int, len(ed.buffer.Lines))
+		var n int
+		for i := range ed.buffer.Lines {
+			ed.wrap.first[i] = n
+			n += len(ed.lineStarts(i))
+		}
+	}
+	return ed.wrap.first[row]
+}
+
+// displayLines returns the total number of display lines.
+func (ed *Editor) displayLines() int {
+	last := len(ed.buffer.Lines) - 1
+	return ed.displayLine(last) + len(ed.lineStarts(last))
+}
+
+// displayRow returns the row containing display line d.
+func (ed *Editor) displayRow(d int) int {
+	last := len(ed.buffer.Lines) - 1
+	if !ed.wrapping() {
+		if d > last {
+			return last
+		}
+		return d
+	}
+	ed.displayLine(0) // make sure the cache is up to date
+	row := sort.Search(len(ed.wrap.first), func(i int) bool {
+		return ed.wrap.first[i] > d
+	}) - 1
+	if row < 0 {
+		return 0
+	}
+	return row
+}
+
+// lineOf returns the index into starts of the display line containing col.
+func lineOf(starts []int, col int) int {
+	return sort.Search(len(starts), func(i int) bool {
+		return starts[i] > col
+	}) - 1
+}
+
+// segment is the part of a buffer line shown on one display line.
+type segment struct {
+	start, end int // columns
+	text       string
+}
+
+// segments splits row into its display lines.
+func (ed *Editor) segments(row int) []segment {
+	rs := []rune(ed.buffer.Lines[row].String())
+	starts := ed.lineStarts(row)
+	segs := make([]segment, len(starts))
+	for k, start := range starts {
+		end := len(rs)
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		segs[k] = segment{start: start, end: end, text: string(rs[start:end])}
+	}
+	return segs
+}
+
+// wrapLine returns the columns at which s is broken into display lines
+// no wider than width. Lines are broken after whitespace where possible.
+func (ed *Editor) wrapLine(s string, width fixed.Int26_6) []int {
+	rs := []rune(s)
+	starts := []int{0}
+	for start := 0; start < len(rs); {
+		var x fixed.Int26_6
+		brk := 0 // the column following the last space
+		i := start
+		for ; i < len(rs); i++ {
+			// tabstops are relative to the start of the display line,
+			// as they are when the line is measured
+			if rs[i] == '\t' {
+				x += ed.tabwidth - x%ed.tabwidth
+			} else {
+				fb := ed.faceFor(rs[i])
+				if i > start && rs[i-1] != '\t' {
+					x += ed.kern(ed.faceFor(rs[i-1]), fb)
+				}
+				x += ed.glyphAdvance(fb)
+			}
+			if x > width && i > start {
+				break
+			}
+			if unicode.IsSpace(rs[i]) {
+				brk = i + 1
+			}
+		}
+		if i == len(rs) {
+			break
+		}
+		if unicode.IsSpace(rs[i]) {
+			// let whitespace hang past the edge
+			i++
+		} else if brk > start {
+			i = brk
+		}
+		if i == len(rs) {
+			break
+		}
+		starts = append(starts, i)
+		start = i
+	}
+	return starts
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"sigint.ca/graphics/editor/address"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestWrapLine(t *testing.T) {
+	ed := NewEditor(basicfont.Face7x13, SimpleTheme)
+	cases := []struct {
+		s    string
+		want []int
+	}{
+		{"", []int{0}},
+		{"short", []int{0}},
+		{"the quick brown fox jumps", []int{0, 10, 20}},
+		{"abcdefghijklmnopqrstuvwxyz", []int{0, 10, 20}},
+		{"0123456789      x", []int{0, 11}},
+	}
+	for _, c := range cases {
+		if got := ed.wrapLine(c.s, fixed.I(10*7)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("wrapLine(%q): got %v, wanted %v", c.s, got, c.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	opts := *SimpleTheme
+	opts.Wrap = true
+	opts.CursorKeys = true
+	ed := NewEditor(basicfont.Face7x13, &opts)
+	ed.Load([]byte("the quick brown fox jumps\nover\nthe lazy dog"))
+
+	// room for 10 columns of text
+	width := 10*7 + 2*ed.margin
+	ed.Draw(image.NewRGBA(image.Rect(0, 0, width, 100)), image.Rect(0, 0, width, 100))
+
+	if got, want := ed.docHeight(), 5*13; got != want {
+		t.Errorf("got document height %d, wanted %d", got, want)
+	}
+	for _, a := range []address.Simple{{0, 0}, {0, 9}, {0, 10}, {0, 24}, {1, 2}, {2, 5}} {
+		if got := ed.getAddress(ed.getPixelsAbs(a)); got != a {
+			t.Errorf("getAddress(getPixelsAbs(%v)) = %v", a, got)
+		}
+	}
+	if got, want := ed.getPixelsAbs(address.Simple{2, 0}).Y, 4*13; got != want {
+		t.Errorf("got y=%d for row 2, wanted %d", got, want)
+	}
+
+	// clicking past the end of a wrapped display line stays on it
+	if got, want := ed.getAddress(image.Pt(width-1, 13)), (address.Simple{0, 19}); got != want {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+
+	// the cursor keys move by display lines
+	ed.SetDot(address.Selection{From: address.Simple{0, 2}, To: address.Simple{0, 2}})
+	down := key.Event{Code: key.CodeDownArrow}
+	for _, want := range []address.Simple{{0, 12}, {0, 22}, {1, 2}} {
+		ed.SendKeyEvent(down)
+		if ed.dot.From != want {
+			t.Errorf("got %v, wanted %v", ed.dot.From, want)
+		}
+	}
+}